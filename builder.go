@@ -0,0 +1,163 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ========================================================================= //
+	A Builder is the same read without struct tags:  each field is named,
+	with its env var, default & any checks, in code the compiler and grep
+	can see, then Bind gives the structure and Read fills it in.
+
+		err := env.New().
+			String("Host", "HOST", "localhost").
+			Int("Port", "PORT", 8080, func(p int) error {
+				if p < 1 || p > 65535 {
+					return errors.New("not a port")
+				}
+				return nil
+			}).
+			Duration("Timeout", "TIMEOUT", 30*time.Second).
+			Bind(&cfg).
+			Read()
+
+	Fields are found by name (Database.Host for a nested one) and must be of
+	the kind the method says (a named type of it is fine).  The values are
+	converted as ReadEnvVars does, and any Options given to New apply.
+// ------------------------------------------------------------------------- */
+
+// Builder -- the fields to read into a structure, see New
+type Builder struct {
+	opts   []Option
+	specs  []fieldSpec
+	target interface{}
+}
+
+// fieldSpec -- a field a Builder reads
+type fieldSpec struct {
+	field, name, def string
+	ok               func(t reflect.Type) bool // the field's type is what the method reads
+	kind             string                    // what ok takes, for errors
+	checks           []func(v reflect.Value) error
+}
+
+// start a Builder, reading as the options say
+func New(opts ...Option) *Builder {
+	return &Builder{opts: opts}
+}
+
+// read the string field from the env var, def if unset, then run any checks on it
+func (b *Builder) String(field, name, def string, checks ...func(string) error) *Builder {
+	spec := fieldSpec{field: field, name: name, def: def, kind: "a string", ok: kindIs(reflect.String)}
+	for _, check := range checks {
+		check := check
+		spec.checks = append(spec.checks, func(v reflect.Value) error { return check(v.String()) })
+	}
+	b.specs = append(b.specs, spec)
+	return b
+}
+
+// read the int field (any width) from the env var, def if unset, then run any checks on it
+func (b *Builder) Int(field, name string, def int, checks ...func(int) error) *Builder {
+	spec := fieldSpec{field: field, name: name, def: strconv.Itoa(def), kind: "an int",
+		ok: kindIs(reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64)}
+	for _, check := range checks {
+		check := check
+		spec.checks = append(spec.checks, func(v reflect.Value) error { return check(int(v.Int())) })
+	}
+	b.specs = append(b.specs, spec)
+	return b
+}
+
+// read the bool field from the env var, def if unset, then run any checks on it
+func (b *Builder) Bool(field, name string, def bool, checks ...func(bool) error) *Builder {
+	spec := fieldSpec{field: field, name: name, def: strconv.FormatBool(def), kind: "a bool", ok: kindIs(reflect.Bool)}
+	for _, check := range checks {
+		check := check
+		spec.checks = append(spec.checks, func(v reflect.Value) error { return check(v.Bool()) })
+	}
+	b.specs = append(b.specs, spec)
+	return b
+}
+
+// read the time.Duration field from the env var, def if unset, then run any checks on it
+func (b *Builder) Duration(field, name string, def time.Duration, checks ...func(time.Duration) error) *Builder {
+	spec := fieldSpec{field: field, name: name, def: def.String(), kind: "a time.Duration",
+		ok: func(t reflect.Type) bool { return t == durationType }}
+	for _, check := range checks {
+		check := check
+		spec.checks = append(spec.checks, func(v reflect.Value) error { return check(time.Duration(v.Int())) })
+	}
+	b.specs = append(b.specs, spec)
+	return b
+}
+
+// set the structure to read into, i is a pointer to it
+func (b *Builder) Bind(i interface{}) *Builder {
+	b.target = i
+	return b
+}
+
+// read each field given into the bound structure, returning the first error
+func (b *Builder) Read() error {
+	v := reflect.ValueOf(b.target)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Read: bound %T, not a pointer to a struct", b.target)
+	}
+	o := newOptions(b.opts)
+	for _, spec := range b.specs {
+		field, err := fieldByPath(v.Elem(), spec.field)
+		if err != nil {
+			return fmt.Errorf("Read: %v", err)
+		}
+		if !spec.ok(field.Type()) {
+			return fmt.Errorf("Read: field %s is a %v, not %s", spec.field, field.Type(), spec.kind)
+		}
+		tags := fieldTags{name: o.prefix + spec.name, field: spec.field, prefix: o.prefix, tagged: true}
+		envVal, err := o.find(&tags)
+		if err != nil {
+			return fieldErr(spec.field, err)
+		}
+		if envVal == "" {
+			envVal = spec.def
+		}
+		if err := setEnvVal(tags, envVal, field); err != nil {
+			return fieldErr(spec.field, err)
+		}
+		for _, check := range spec.checks {
+			if err := check(field); err != nil {
+				return fieldErr(spec.field, fmt.Errorf("%s=%q: %v", tags.name, envVal, err))
+			}
+		}
+	}
+	return nil
+}
+
+// kindIs -- a Builder type check for any of the kinds
+func kindIs(kinds ...reflect.Kind) func(reflect.Type) bool {
+	return func(t reflect.Type) bool {
+		for _, k := range kinds {
+			if t.Kind() == k && t != durationType {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// fieldByPath -- the settable field named by path, nested ones dotted:  Database.Host
+func fieldByPath(v reflect.Value, path string) (reflect.Value, error) {
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("no field %s, %v isn't a struct", path, v.Type())
+		}
+		if v = v.FieldByName(name); !v.IsValid() || !v.CanSet() {
+			return reflect.Value{}, fmt.Errorf("no exported field %s", path)
+		}
+	}
+	return v, nil
+}
@@ -0,0 +1,142 @@
+package env
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/* ========================================================================= //
+	For seeing what ReadEnvVars would do with a structure, without changing
+	it:  Snapshot maps each env var name ReadEnvVars would look up to the
+	raw value found, or SnapshotUnset if the env var isn't set.  A field with
+	alternate names shows the one it is read from, a map field each env var
+	it gathers (or PREFIX_* as unset).  The values of fields tagged
+	`env:"NAME,secret"` are shown as SecretMask.
+
+	Dump is for logging a structure once read:  a line per field, in the
+	order they're declared, of the env var name, the field's value as
+	WriteEnvVars would write it, and the field & its type:
+		PORT=8080 (field Port int)
+		DB_PASSWORD=*** (field DB.Password string)
+// ------------------------------------------------------------------------- */
+
+// SnapshotUnset -- Snapshot's value for an env var that isn't set
+const SnapshotUnset = "<unset>"
+
+// SecretMask -- what a secret field's value is shown as
+const SecretMask = "***"
+
+// return the env var names the structure's fields read, with their raw values, looked up as changed by any
+// options (FromMap, WithPrefix, AnyCase, FileSecrets...) as the readers would;  nil if i isn't a pointer to a struct
+func Snapshot(i interface{}, opts ...Option) map[string]string {
+	snap := map[string]string{}
+	o := newOptions(opts)
+	show := func(tags fieldTags, name, envVal string) {
+		if tags.secret && envVal != "" {
+			envVal = SecretMask
+		}
+		snap[name] = envVal
+	}
+	var look walkFn
+	look = func(tags fieldTags, path string, field reflect.Value) error {
+		switch {
+		case tags.format != "":
+		case field.Kind() == reflect.Map:
+			gather, found := tags.mapPrefix(), false
+			for name, envVal := range o.vars() {
+				if strings.HasPrefix(name, gather) && len(name) > len(gather) {
+					show(tags, name, envVal)
+					found = true
+				}
+			}
+			if !found {
+				snap[gather+"*"] = SnapshotUnset
+			}
+			return nil
+		case structSlice(field.Type()):
+			vars := o.vars()
+			for n := 0; anyPrefixed(vars, fmt.Sprintf("%s_%d_", tags.name, n)); n++ {
+				elem := reflect.New(field.Type().Elem()).Elem()
+				walkStruct(o, fmt.Sprintf("%s_%d_", tags.name, n), fmt.Sprintf("%s[%d].", path, n), elem, look)
+			}
+			return nil
+		}
+		found := tags
+		envVal, err := o.find(&found)
+		switch {
+		case err != nil:
+			snap[tags.name] = "<" + err.Error() + ">"
+		case envVal != "":
+			show(tags, found.name, envVal) // the name it was found under, maybe an alternate
+		default:
+			// unset, or only blank:  the first set shown as is, else the name as unset
+			for _, name := range o.names(&tags) {
+				if envVal, set := o.lookup(name); set {
+					snap[name] = envVal
+					return nil
+				}
+			}
+			snap[tags.name] = SnapshotUnset
+		}
+		return nil
+	}
+	v, err := structPtr("Snapshot", i)
+	if err != nil {
+		return nil
+	}
+	walkStruct(o, o.prefix, "", v, look)
+	return snap
+}
+
+// return the structure's fields a line each as ENVNAME=value (field Path Type), secret values masked;
+// a line with the error if i isn't a pointer to a struct
+func Dump(i interface{}) string {
+	var sb strings.Builder
+	line := func(tags fieldTags, name, envVal, path string, t reflect.Type) {
+		if tags.secret && envVal != "" {
+			envVal = SecretMask
+		}
+		fmt.Fprintf(&sb, "%s=%s (field %s %v)\n", name, envVal, path, t)
+	}
+	o := newOptions(nil)
+	o.skipNil = true // nothing to show
+	var dump walkFn
+	dump = func(tags fieldTags, path string, field reflect.Value) error {
+		switch {
+		case tags.format != "":
+		case field.Kind() == reflect.Map:
+			if field.Type().Key().Kind() != reflect.String {
+				return nil // not keyed by name, so not from env vars (nor written)
+			}
+			keys := field.MapKeys()
+			sort.Slice(keys, func(a, b int) bool { return keys[a].String() < keys[b].String() })
+			for _, k := range keys {
+				key, elem := k.String(), field.MapIndex(k)
+				line(tags, tags.mapPrefix()+key, fmt.Sprint(elem.Interface()), path+"["+key+"]", elem.Type())
+			}
+			return nil
+		case structSlice(field.Type()):
+			for n := 0; n < field.Len(); n++ {
+				walkStruct(o, fmt.Sprintf("%s_%d_", tags.name, n), fmt.Sprintf("%s[%d].", path, n), field.Index(n), dump)
+			}
+			return nil
+		}
+		envVal, ok, err := fmtEnvVal(tags, field)
+		switch {
+		case err != nil:
+			envVal = "<" + err.Error() + ">"
+		case !ok:
+			envVal = SnapshotUnset // not written:  a nil pointer, a false presence bool
+		}
+		line(tags, tags.name, envVal, path, field.Type())
+		return nil
+	}
+	v, err := structPtr("Dump", i)
+	if err != nil {
+		return "<" + err.Error() + ">\n"
+	}
+	walkStruct(o, o.prefix, "", v, dump)
+	return sb.String()
+}
@@ -0,0 +1,52 @@
+package env
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	var c struct {
+		A        string            `env:"ALT_A,ALT_B"`
+		Port     int               `default:"80"`
+		Blank    string            `env:"BLANK,BLANK_OLD"`
+		Password string            `env:"DB_PASSWORD,secret"`
+		Labels   map[string]string `prefix:"LBL_"`
+		None     map[string]string
+		Servers  []struct{ Host string }
+	}
+	src := map[string]string{"ALT_B": "b", "BLANK": " ", "DB_PASSWORD": "hunter2", "LBL_env": "prod", "SERVERS_0_HOST": "s0"}
+	want := map[string]string{
+		"ALT_B":          "b",
+		"PORT":           SnapshotUnset,
+		"BLANK":          " ",
+		"DB_PASSWORD":    SecretMask,
+		"LBL_env":        "prod",
+		"NONE_*":         SnapshotUnset,
+		"SERVERS_0_HOST": "s0",
+	}
+	if got := Snapshot(&c, FromMap(src)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Snapshot = %v\n want %v", got, want)
+	}
+	if c.A != "" || c.Labels != nil || c.Servers != nil {
+		t.Errorf("Snapshot changed the structure: %+v", c)
+	}
+
+	var p struct{ Host string }
+	t.Setenv("APP_HOST", "h")
+	if got := Snapshot(&p, WithPrefix("APP")); got["APP_HOST"] != "h" || len(got) != 1 {
+		t.Errorf("Snapshot with a prefix = %v", got)
+	}
+}
+
+func TestDumpMaps(t *testing.T) {
+	type level string
+	c := struct {
+		Labels map[level]string
+		ByID   map[int]string
+	}{map[level]string{"b": "2", "a": "1"}, map[int]string{1: "x"}}
+	want := "LABELS_a=1 (field Labels[a] string)\nLABELS_b=2 (field Labels[b] string)\n"
+	if got := Dump(&c); got != want {
+		t.Errorf("Dump = %q, want %q", got, want)
+	}
+}
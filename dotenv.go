@@ -0,0 +1,146 @@
+package env
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* ========================================================================= //
+	LoadDotEnv reads a .env file into the environment so a following call to
+	ReadEnvVars picks its values up:
+
+		# comments and blank lines are ignored
+		HOST=localhost
+		export PORT=8080           # 'export ' is allowed, as are trailing comments
+		GREETING="hello\tworld"    # double quotes take Go escapes
+		RAW='no $escapes\here'     # single quotes are taken literally
+
+	Env vars already set in the process win over the file's values, use
+	LoadDotEnvOverride to have the file's values win instead.  Both error on a
+	missing file or a malformed line, giving the line number.
+
+	ReadEnvVarsReader reads a structure straight from such lines, without
+	going through the environment.
+// ------------------------------------------------------------------------- */
+
+// load the KEY=VALUE lines of the file into the environment, not replacing any env vars already set
+func LoadDotEnv(path string) error {
+	return loadDotEnv(path, false)
+}
+
+// load the KEY=VALUE lines of the file into the environment, replacing any env vars already set
+func LoadDotEnvOverride(path string) error {
+	return loadDotEnv(path, true)
+}
+
+// as ReadEnvVarsFromErr, the values parsed from r's KEY=VALUE lines (as in a .env file), not the environment
+func ReadEnvVarsReader(r io.Reader, i interface{}) error {
+	vars, err := parseDotEnv(r)
+	if err != nil {
+		return fmt.Errorf("ReadEnvVarsReader: %v", err)
+	}
+	src := make(map[string]string, len(vars))
+	for _, kv := range vars {
+		src[kv[0]] = kv[1]
+	}
+	return ReadEnvVarsFromErr(src, i)
+}
+
+// loadDotEnv -- read the file, setting each var if not already set or overwrite
+func loadDotEnv(path string, overwrite bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("LoadDotEnv: %v", err)
+	}
+	defer f.Close()
+
+	vars, err := parseDotEnv(f)
+	if err != nil {
+		return fmt.Errorf("LoadDotEnv: %s:%v", path, err)
+	}
+	for _, kv := range vars {
+		if _, set := os.LookupEnv(kv[0]); set && !overwrite {
+			continue
+		}
+		if err := os.Setenv(kv[0], kv[1]); err != nil {
+			return fmt.Errorf("LoadDotEnv: %v", err)
+		}
+	}
+	return nil
+}
+
+// parseDotEnv -- return the KEY, VALUE pairs in the order read, errors give the line number
+func parseDotEnv(r io.Reader) ([][2]string, error) {
+	vars := [][2]string{}
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		text = strings.TrimPrefix(text, "export ")
+		eq := strings.Index(text, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("%d: missing '=' in %q", line, text)
+		}
+		key := strings.TrimSpace(text[:eq])
+		if key == "" {
+			return nil, fmt.Errorf("%d: missing name in %q", line, text)
+		}
+		val, err := dotEnvValue(strings.TrimSpace(text[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%d: %v", line, err)
+		}
+		vars = append(vars, [2]string{key, val})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// dotEnvValue -- unquote the value, or strip any trailing comment from an unquoted one
+func dotEnvValue(val string) (string, error) {
+	if val == "" {
+		return val, nil
+	}
+	switch quote := val[0]; quote {
+	case '"', '\'':
+		end := closingQuote(val)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote in %s", val)
+		}
+		if rest := strings.TrimSpace(val[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+			return "", fmt.Errorf("unexpected %q after quoted value", rest)
+		}
+		if quote == '\'' {
+			return val[1:end], nil
+		}
+		return strconv.Unquote(val[:end+1])
+	}
+	if c := strings.Index(val, " #"); c >= 0 {
+		val = strings.TrimSpace(val[:c])
+	}
+	return val, nil
+}
+
+// closingQuote -- the index of the quote closing the one val starts with, -1 if none:  the first after
+// it, not escaped by a '\' in double quotes (single quotes have no escapes), so any comment after is left
+func closingQuote(val string) int {
+	quote := val[0]
+	for n := 1; n < len(val); n++ {
+		switch val[n] {
+		case '\\':
+			if quote == '"' {
+				n++ // whatever it escapes
+			}
+		case quote:
+			return n
+		}
+	}
+	return -1
+}
@@ -0,0 +1,61 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDotEnvValue(t *testing.T) {
+	tests := []struct {
+		val, want string
+		bad       bool
+	}{
+		{val: "plain", want: "plain"},
+		{val: "plain # comment", want: "plain"},
+		{val: `"a\tb"`, want: "a\tb"},
+		{val: `"a" # say "hi"`, want: "a"},
+		{val: `"say \"hi\"" # and "bye"`, want: `say "hi"`},
+		{val: `"back\\" # slash`, want: `back\`},
+		{val: `'$x\y' # it's raw`, want: `$x\y`},
+		{val: `''`, want: ""},
+		{val: `"open`, bad: true},
+		{val: `"a" b`, bad: true},
+		{val: `'a' "b"`, bad: true},
+	}
+	for _, tc := range tests {
+		got, err := dotEnvValue(tc.val)
+		switch {
+		case tc.bad && err == nil:
+			t.Errorf("%s read as %q, want an error", tc.val, got)
+		case !tc.bad && (err != nil || got != tc.want):
+			t.Errorf("%s read as %q (%v), want %q", tc.val, got, err, tc.want)
+		}
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	lines := "# comment\n\nDOT_A=file\nexport DOT_B = \"two\" # quoted\nDOT_C='c'\n"
+	if err := os.WriteFile(path, []byte(lines), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOT_A", "proc")
+	t.Setenv("DOT_B", "")
+	t.Setenv("DOT_C", "")
+	os.Unsetenv("DOT_B")
+	os.Unsetenv("DOT_C")
+	if err := LoadDotEnv(path); err != nil {
+		t.Fatal(err)
+	}
+	if os.Getenv("DOT_A") != "proc" || os.Getenv("DOT_B") != "two" || os.Getenv("DOT_C") != "c" {
+		t.Errorf("LoadDotEnv: DOT_A=%q DOT_B=%q DOT_C=%q", os.Getenv("DOT_A"), os.Getenv("DOT_B"), os.Getenv("DOT_C"))
+	}
+	if err := LoadDotEnvOverride(path); err != nil || os.Getenv("DOT_A") != "file" {
+		t.Errorf("LoadDotEnvOverride: DOT_A=%q, %v", os.Getenv("DOT_A"), err)
+	}
+	if _, err := parseDotEnv(strings.NewReader("A=1\nBAD\n")); err == nil || !strings.HasPrefix(err.Error(), "2:") {
+		t.Errorf("BAD line: %v, want a line 2 error", err)
+	}
+}
@@ -0,0 +1,80 @@
+package env
+
+import (
+	"encoding/binary"
+	"io"
+	"math/bits"
+)
+
+/* ========================================================================= //
+	Helpers built on MyEncoding / NotMyEncoding, for reading & writing binary
+	data in a known byte order.  A nil order means the system's own.
+
+	ReadStruct & WriteStruct take what binary.Read & binary.Write do: fixed
+	size values, or structs & arrays of them.
+
+	ToMyEndian16/32/64 take a value read as order and swap it only if that
+	isn't MyEncoding, going by the bytes the order gives and not its type, so
+	binary.NativeEndian is mine.
+// ------------------------------------------------------------------------- */
+
+// read the fixed size data v points at from r, nil order reads in MyEncoding
+func ReadStruct(r io.Reader, order binary.ByteOrder, v interface{}) error {
+	if order == nil {
+		order = myEncoding
+	}
+	return binary.Read(r, order, v)
+}
+
+// write the fixed size data of v to w, nil order writes in MyEncoding
+func WriteStruct(w io.Writer, order binary.ByteOrder, v interface{}) error {
+	if order == nil {
+		order = myEncoding
+	}
+	return binary.Write(w, order, v)
+}
+
+// byte swap a 16 bit value
+func SwapUint16(v uint16) uint16 {
+	return bits.ReverseBytes16(v)
+}
+
+// byte swap a 32 bit value
+func SwapUint32(v uint32) uint32 {
+	return bits.ReverseBytes32(v)
+}
+
+// byte swap a 64 bit value
+func SwapUint64(v uint64) uint64 {
+	return bits.ReverseBytes64(v)
+}
+
+// swap the 16 bit value from order to MyEncoding, if they differ
+func ToMyEndian16(v uint16, order binary.ByteOrder) uint16 {
+	if isMine(order) {
+		return v
+	}
+	return SwapUint16(v)
+}
+
+// swap the 32 bit value from order to MyEncoding, if they differ
+func ToMyEndian32(v uint32, order binary.ByteOrder) uint32 {
+	if isMine(order) {
+		return v
+	}
+	return SwapUint32(v)
+}
+
+// swap the 64 bit value from order to MyEncoding, if they differ
+func ToMyEndian64(v uint64, order binary.ByteOrder) uint64 {
+	if isMine(order) {
+		return v
+	}
+	return SwapUint64(v)
+}
+
+// isMine -- if the order reads the bytes as MyEncoding does, nil is
+func isMine(order binary.ByteOrder) bool {
+	b := []byte{1, 0}
+	return order == nil || order.Uint16(b) == myEncoding.Uint16(b)
+}
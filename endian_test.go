@@ -0,0 +1,86 @@
+package env
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// endianVectors -- the bytes 01 02 .. 08 as read in each order
+var endianVectors = []struct {
+	order binary.ByteOrder
+	v16   uint16
+	v32   uint32
+	v64   uint64
+}{
+	{binary.BigEndian, 0x0102, 0x01020304, 0x0102030405060708},
+	{binary.LittleEndian, 0x0201, 0x04030201, 0x0807060504030201},
+}
+
+func TestToMyEndian(t *testing.T) {
+	data := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	want16, want32, want64 := myEncoding.Uint16(data), myEncoding.Uint32(data), myEncoding.Uint64(data)
+	for _, tc := range endianVectors {
+		if tc.order.Uint64(data) != tc.v64 {
+			t.Fatalf("%v: test vector %#x isn't what it reads", tc.order, tc.v64)
+		}
+		if got := ToMyEndian16(tc.v16, tc.order); got != want16 {
+			t.Errorf("ToMyEndian16(%#x, %v) = %#x, want %#x", tc.v16, tc.order, got, want16)
+		}
+		if got := ToMyEndian32(tc.v32, tc.order); got != want32 {
+			t.Errorf("ToMyEndian32(%#x, %v) = %#x, want %#x", tc.v32, tc.order, got, want32)
+		}
+		if got := ToMyEndian64(tc.v64, tc.order); got != want64 {
+			t.Errorf("ToMyEndian64(%#x, %v) = %#x, want %#x", tc.v64, tc.order, got, want64)
+		}
+	}
+
+	// the native order is mine, whatever its type
+	for _, order := range []binary.ByteOrder{nil, myEncoding, binary.NativeEndian} {
+		if got := ToMyEndian16(0x1234, order); got != 0x1234 {
+			t.Errorf("ToMyEndian16(0x1234, %v) = %#x", order, got)
+		}
+		if got := ToMyEndian32(0x12345678, order); got != 0x12345678 {
+			t.Errorf("ToMyEndian32(0x12345678, %v) = %#x", order, got)
+		}
+		if got := ToMyEndian64(0x123456789abcdef0, order); got != 0x123456789abcdef0 {
+			t.Errorf("ToMyEndian64(0x123456789abcdef0, %v) = %#x", order, got)
+		}
+	}
+}
+
+func TestSwap(t *testing.T) {
+	if got := SwapUint16(0x0102); got != 0x0201 {
+		t.Errorf("SwapUint16 = %#x", got)
+	}
+	if got := SwapUint32(0x01020304); got != 0x04030201 {
+		t.Errorf("SwapUint32 = %#x", got)
+	}
+	if got := SwapUint64(0x0102030405060708); got != 0x0807060504030201 {
+		t.Errorf("SwapUint64 = %#x", got)
+	}
+}
+
+func TestReadWriteStruct(t *testing.T) {
+	type rec struct {
+		A uint16
+		B uint32
+	}
+	for _, tc := range endianVectors {
+		var buf bytes.Buffer
+		if err := WriteStruct(&buf, tc.order, rec{0x0102, 0x01020304}); err != nil {
+			t.Fatal(err)
+		}
+		want := []byte{1, 2, 1, 2, 3, 4}
+		if tc.order == binary.LittleEndian {
+			want = []byte{2, 1, 4, 3, 2, 1}
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Errorf("WriteStruct(%v) = % x, want % x", tc.order, buf.Bytes(), want)
+		}
+		var back rec
+		if err := ReadStruct(&buf, tc.order, &back); err != nil || back != (rec{0x0102, 0x01020304}) {
+			t.Errorf("ReadStruct(%v) = %#v, %v", tc.order, back, err)
+		}
+	}
+}
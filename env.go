@@ -1,12 +1,25 @@
 package env
 
 import (
+	"database/sql"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 	"unsafe"
 )
 
@@ -17,9 +30,123 @@ import (
 		Then MAIN
 
 	So, we use variable declaration to make sure the env is read in before
-	other package 'init' functions, which could use this env package.
+	other package 'init' functions, which could use this env package.  The
+	read itself is behind a sync.Once (captureEnv), so it is done exactly
+	once however the package comes to be initialized, and Ready reports it
+	was done.
 
-	ReadEnvVars will handle strings, ints & []strings -- see envSep below
+	ReadEnvVars handles fields of these kinds -- see envSep below for the slices:
+		string                     (limit with a `oneof:"debug info warn"` tag, add ',ci' to ignore case)
+		int, uint of any width     (values that don't fit the field are rejected)
+		                           (as Go literals: -42, 0xff, 0o644, 0b101, 1_000 -- so 010 is 8 and
+		                            0080 an error, where they once read 10 & 80:  give zero padded
+		                            ones a `base:"10"` tag)
+		                           (limit with `min:"1" max:"64"` tags)
+		                           (a `pct:"true"` tag allows a trailing '%': CACHE_PCT=75%)
+		                           (a `bytes:"true"` tag reads sizes: 512, 10MB, 1.5G, 64KiB --
+		                            K/M/G/T/P are 1000 based, KiB/MiB... 1024 based)
+		uintptr
+		float32, float64
+		complex64, complex128      (as strconv.ParseComplex: 1+2i)
+		bool                       (1/0, true/false, yes/no, on/off -- case-insensitive)
+		[]string, []int            (split on envSep, or a `sep:","` tag)
+		[]time.Duration, []float64 (and slices of the other kinds, split the same way:
+		                            BACKOFFS=100ms:500ms:2s)
+		[N]string, [N]int...       (split as slices, needing exactly N elements: Coords [3]int)
+		[]byte                     (raw, or decoded per an `encoding:"hex"` / `encoding:"base64"` tag)
+		map[string]string          (see below, also map[string]int, map[string]bool... converting
+		                            each value as a field of that type)
+		pointers to any of these   (only allocated when their env var is set)
+		structs                    (see below)
+	and the types:
+		time.Duration              (Go durations: 30s, 1h15m...)
+		time.Time                  (RFC3339, or as given by a `layout:"2006-01-02"` tag)
+		net.IP                     (v4 or v6: 10.0.0.1, ::1)
+		net.IPNet                  (CIDR: 10.0.0.0/8)
+		[]net.IPNet                (split as other slices: 10.0.0.0/8:192.168.0.0/16, give
+		                            IPv6 ones a `sep:","` tag as ':' is in them)
+		url.URL, *url.URL          (require a scheme with a `scheme:"https"` or `scheme:"http https"` tag)
+		os.FileMode                (octal permissions: 644, 0644, 0o644)
+		*big.Int                   (base 10, or as a `base:"16"` tag says, base 0 takes 0x.. 0o.. 0b..)
+		*big.Float                 (as big.Float's SetString: 1.5, 1e100)
+		sql.NullString, NullInt64, (the value, with Valid set, only if the env var is set:
+		NullBool, NullFloat64...    like a pointer's nil, unset leaves Valid false)
+	An `expand:"true"` tag expands ${VAR} and $VAR in the value, or default,
+	as the shell would:  LOG_DIR=${HOME}/logs.  Values holding references are
+	expanded again, up to maxExpand times, an error if they still change then
+	(as A=$B B=$A loops do).  Without the tag a '$' is just a '$'.
+
+	An interface{} field is read as the type its `as` tag names:  string (the
+	default), int, int64, uint, uint64, float64, bool, duration, time, []string
+	or []int, holding that type once read.
+
+	Types of your own can be read with RegisterParser (see parsers.go).
+	Without tags at all, New gives a Builder naming each field's env var and
+	default in code (see builder.go).
+
+	Named types read as their kind, a `type Level string` field is a string,
+	as are slices of them ([]Level) and named slices (type Tags []string).
+	Any other kind (chan, func, interface...) is an error, but only if its env
+	var is set, unset fields are left alone whatever their kind.
+
+	The `env` tag can list alternate names after the name, tried left to right
+	with the first holding a value used:  `env:"DATABASE_URL,DB_URL,PG_URL"`.  Anything
+	after the name that is one of the options (required, nonempty...) is that
+	option, not a name.
+
+	Bools normally read their value, NO_COLOR=0 is false.  An `env:"NO_COLOR,presence"`
+	tag instead makes the field true if the env var is set at all, even empty,
+	and false if not, as NO_COLOR & CI are meant (the `default` tag is unused).
+
+	An `env:"DB_PASSWORD,secret"` tag still reads the value into the field, but
+	it is shown as SecretMask (***) in any error, Snapshot or Dump, so the
+	resolved config can be logged safely.
+
+	Slices keep any empty elements (A::B is "A", "", "B"), an `env:"NAME,nonempty"`
+	tag drops them after splitting, on envSep or the `sep` tag, and any trimming.
+	Only a []string (or other string kind) can hold one, an empty element of
+	any other slice or array (PORTS=80::443) is an error.
+	Split on a `sep` tag the elements are always trimmed and empty ones dropped,
+	as people write lists:  " A, B ,,C," with `sep:","` is "A", "B", "C" -- add
+	an `env:"NAME,raw"` tag where the whitespace or empty elements matter.
+
+	An env var that is empty or only whitespace (PORT=" ") reads as unset,
+	whatever the field's type:  the field keeps its value or gets its
+	`default`, a required one is missing.
+
+	`required` only asks for the env var to have a value, a `notEmpty:"true"`
+	tag checks what the string, slice or map field holds once read (from the
+	env var or a default):  TAGS=", ," with `sep:","` is set, but gives an empty
+	slice, which notEmpty rejects.  (Not to be confused with the `nonempty`
+	option, dropping a slice's empty elements.)
+
+	A `trim:"true"` tag trims whitespace from around the value (and each slice
+	element) before converting it, `trim:"quotes"` then also strips a layer of
+	matching quotes:  a PORT of  "8080"  reads as 8080.
+
+	map[string]string fields gather every env var with a prefix, keyed by the
+	rest of the name:  Labels gets LABELS_env=prod as "env", or set the prefix
+	with a `prefix:"LABEL_"` tag, `prefix:"LABEL_,lower"` lower cases the keys.
+
+	A `format:"json"` tag unmarshals the value into the field instead, for any
+	type encoding/json takes:  RULES={"a":1,"b":2} for a map[string]int.
+
+	A `required` map or slice of structs needs at least one env var gathered:
+	LBL_* for a map with `prefix:"LBL_"`, SERVERS_0_* for Servers []Server.
+
+	Two fields reading the same env var (MaxConns & Maxconns both MAXCONNS, the
+	same tag twice, or one's alternate name another's) are a mistake in the
+	structure, the readers error naming both.
+
+	Nested structs are read too, their field names prefixed with the struct's
+	name (Database.Host reads DATABASE_HOST), embedded structs are flattened,
+	their fields keeping their own tags, but an outer field with the same env
+	var name wins and the embedded one is left alone, as Go promotes fields.
+	An embedded pointer (*Common) is flattened the same, a nil one is only
+	allocated if anything is read into it (not for an unexported *common).
+	Slices of structs read their elements from indexed names: Servers []Server
+	reads SERVERS_0_HOST, SERVERS_0_PORT, SERVERS_1_HOST... stopping at the first
+	index with no env vars set.
 
 	An outside package can call ReadEnvVars to retrieve any environment vars
 	specific for it:
@@ -27,123 +154,1439 @@ import (
 		var myEnvVars struct {
 			Foo    string // names must be capitalized for this package to read them
 			Bar    int
-			foobar data{} // non-cap names are private and aren't touched
+			MaxFoo int      `env:"MAX_FOO"`         // tag overrides the derived name 'MAXFOO'
+			Baz    int      `default:"42"`          // used when BAZ is not set
+			DbUrl  string   `env:"DB_URL,required"` // ReadEnvVarsErr errors if DB_URL is not set
+			PgUrl  string   `env:"PG_URL,PGURL"`    // PGURL is read if PG_URL isn't set
+			Other  string   `env:"-"`               // skipped, as if not capitalized
+			Tags   []string `sep:","`               // split on ',' not envSep, trim spaces around each
+			foobar data{}   // non-cap names are private and aren't touched
 		}
 
 		func init() {
 			env.ReadEnvVars(&myEnvVars)
 		}
-// ------------------------------------------------------------------------- */
 
-const envSep = ":" // what to split any string slices with, ':' for linux, ';' for windows
+	The accessors (Host, User, HomeDir...) are safe to use from any goroutine,
+	even while Refresh is re-reading them:  the facts are kept in one struct
+	behind a RWMutex, read as a copy and replaced whole.  Any future accessor
+	must go through current() for the same reason.
+// ------------------------------------------------------------------------- */
 
 var (
-	envSet = getEnv() // doing this gets the environment vars before any init() function(s) are called
+	goos   = runtime.GOOS  // the system to act as, only ever changed by Reset for tests
+	envSep = listSep(goos) // what to split any string slices with, ':' for linux & other unix, ';' for windows
 
-	env struct {
-		Host string // host name (read on linux, assigned on wondows)
-		User string // user name (read on linux, re-read from username on windows)
-	}
+	littleEndian              = littleEndianCheck()
+	myEncoding, notMyEncoding = byteOrders(littleEndian)
+
+	osArch   = runtime.GOARCH
+	numCPU   = runtime.NumCPU // the machine's CPUs, a var so tests can stub it (then Reset)
+	wordSize = int(unsafe.Sizeof(uintptr(0))) * 8
+
+	envOnce sync.Once      // the first read of the package's env vars
+	envSet  = captureEnv() // doing this gets the environment vars before any init() function(s) are called
+
+	envMu sync.RWMutex // guards env, which Refresh can replace
+	env   envFacts
 )
 
-// return current HOST system: 'linux' | 'windows'
+// envFacts -- what the package itself reads from the environment
+type envFacts struct {
+	Host  string   // host name (read on linux, assigned on wondows)
+	User  string   // user name (read on linux, re-read from username on windows)
+	Home  string   // home dir (read on linux, re-read from userprofile on windows)
+	Shell string   // invoking shell (read on linux, assigned on windows)
+	Term  string   // terminal type
+	Path  []string // PATH dirs, split on envSep
+
+	GoRoot string   // GOROOT, else that of the go on the PATH
+	GoPath []string // GOPATH dirs, split on envSep, else ~/go
+	GoBin  string   // GOBIN, else the first GoPath dir's bin
+
+	TZ     string // time zone name, for TimeZone
+	Locale string `env:"LC_ALL,LC_CTYPE,LANG"` // the first set of these, as the C library takes them
+
+	read      bool           // the env vars have been read
+	rawUser   string         // user name as read, may be DOMAIN\user on windows (not cap, so not read itself)
+	wsl       bool           // running under WSL
+	container string         // container runtime, if in one
+	zone      *time.Location // TZ's location, else time.Local
+	cpus      int            // CPUs to use, for NumCPU
+}
+
+// current -- a copy of env, safe against a concurrent Refresh
+func current() envFacts {
+	envMu.RLock()
+	defer envMu.RUnlock()
+	return env
+}
+
+// captureEnv -- do the first read of the package's env vars, only once however often it's called
+func captureEnv() bool {
+	envOnce.Do(func() { getEnv() })
+	return true
+}
+
+// return if the package's own env vars (Host, User...) have been read, for dependents to assert
+// the environment was captured;  always true once the package is initialized
+func Ready() bool {
+	return current().read
+}
+
+// re-read the package's own env vars (Host, User...), for after the environment was changed;
+// any structures read with ReadEnvVars have to be re-read by their owners
+func Refresh() {
+	getEnv()
+}
+
+// for tests:  drop the package's env vars (Host, User...) and the list separator, then act as if running
+// on system ("" for the real one):  the separator and an unset HOST follow it, so the windows handling of
+// User, Shell... can be tried anywhere;  not to be called while other goroutines use the package
+func Reset(system string) {
+	if system == "" {
+		system = runtime.GOOS
+	}
+	envMu.Lock()
+	env = envFacts{}
+	envMu.Unlock()
+	goos = system
+	envSep = listSep(goos)
+	getEnv()
+}
+
+// return current HOST system: 'linux' | 'windows' | 'darwin' ...
 func Host() string {
-	return env.Host
+	return current().Host
 }
 
 // return current USER name
 func User() string {
-	return env.User
+	return current().User
+}
+
+// override the detected host ('linux' | 'windows'...), as IsWindows & the others go by, until the next Refresh
+func SetHost(host string) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	env.Host = host
+}
+
+// override the detected user name (RawUser too), until the next Refresh
+func SetUser(user string) {
+	envMu.Lock()
+	defer envMu.Unlock()
+	env.User, env.rawUser = user, user
+}
+
+// return current USER name as read, on windows this may be DOMAIN\user where User() is only the user
+func RawUser() string {
+	return current().rawUser
+}
+
+// return current user's HOME directory
+func HomeDir() string {
+	return current().Home
+}
+
+// return invoking SHELL: 'bash', 'zsh'... on linux, 'cmd' | 'powershell' on windows
+func Shell() string {
+	return current().Shell
+}
+
+// return TERMinal type
+func Terminal() string {
+	return current().Term
+}
+
+// return the PATH directories, split on ListSep and dropping any empty entries
+func Path() []string {
+	return append([]string(nil), current().Path...)
+}
+
+// return GOROOT, defaulting to that of the go found on the PATH ($GOROOT/bin/go), "" if there's none
+func GoRoot() string {
+	return current().GoRoot
+}
+
+// return the GOPATH directories, split as Path() is, defaulting to ~/go
+func GoPath() []string {
+	return append([]string(nil), current().GoPath...)
+}
+
+// return GOBIN, defaulting to the first GOPATH directory's bin
+func GoBin() string {
+	return current().GoBin
+}
+
+// return the time zone TZ names (America/New_York, UTC...), else time.Local
+func TimeZone() *time.Location {
+	return current().zone
+}
+
+// return the CPUs to size work for:  runtime.NumCPU, or GOMAXPROCS if that's set lower
+func NumCPU() int {
+	return current().cpus
+}
+
+// return the locale from LC_ALL, else LC_CTYPE, else LANG:  en_US.UTF-8, or "" if none are set
+func Locale() string {
+	return current().Locale
+}
+
+// return the list separator used to split []string values, ':' on unix, ';' on windows
+func ListSep() string {
+	return envSep
 }
 
 // simple boolean if system is 'linux'
 func IsLinux() bool {
-	return env.Host == "linux"
+	return current().Host == "linux"
 }
 
 // simple boolean if system is 'windows'
 func IsWindows() bool {
-	return env.Host == "windows"
+	return current().Host == "windows"
+}
+
+// simple boolean if system is 'darwin' (macOS)
+func IsDarwin() bool {
+	return current().Host == "darwin"
+}
+
+// simple boolean if system is unix-like: linux, darwin or one of the BSDs
+func IsUnix() bool {
+	switch current().Host {
+	case "linux", "darwin", "freebsd", "netbsd", "openbsd", "dragonfly":
+		return true
+	}
+	return false
+}
+
+// return the system's architecture: 'amd64' | 'arm64' | '386' ...
+func OSArch() string {
+	return osArch
+}
+
+// return the system's word size in bits: 32 | 64
+func WordSize() int {
+	return wordSize
 }
 
 // return if system is little endian
 func ImLittleEndian() bool {
-	et := 1
-	return *(*byte)(unsafe.Pointer(&et)) == 1
+	return littleEndian
 }
 
 // return if system is little endian
 func ImBigEndian() bool {
-	return !ImLittleEndian()
+	return !littleEndian
 }
 
 // return proper system encoding
 func MyEncoding() binary.ByteOrder {
-	if ImLittleEndian() {
-		return binary.LittleEndian
-	}
-	return binary.BigEndian
+	return myEncoding
 }
 
 // return non native encoding
 func NotMyEncoding() binary.ByteOrder {
-	if ImBigEndian() {
-		return binary.LittleEndian
+	return notMyEncoding
+}
+
+// littleEndianCheck -- look at how an int is stored, only done once as it can't change
+func littleEndianCheck() bool {
+	et := 1
+	return *(*byte)(unsafe.Pointer(&et)) == 1
+}
+
+// byteOrders -- native & non native encodings
+func byteOrders(little bool) (binary.ByteOrder, binary.ByteOrder) {
+	if little {
+		return binary.LittleEndian, binary.BigEndian
 	}
-	return binary.BigEndian
+	return binary.BigEndian, binary.LittleEndian
 }
 
-// read the env vars and try matching them into any structure passed
+// OnMissing -- if set, called by the readers for every field whose env var is unset or blank (before
+// any default is applied), to audit what a config is missing; set it before reading, it isn't guarded
+// (each read takes it as it starts), and it isn't called for the package's own (Host, User...)
+var OnMissing func(field, envName string)
+
+// read the env vars and try matching them into any structure passed, panics on any error
 func ReadEnvVars(i interface{}) {
-	v := reflect.ValueOf(i).Elem()
-	t := v.Type()
+	if err := ReadEnvVarsErr(i); err != nil {
+		panic(err.Error())
+	}
+}
+
+// read the env vars and try matching them into any structure passed, returns the first error found
+// or, once all fields are read, an error listing every missing 'required' env var
+func ReadEnvVarsErr(i interface{}) error {
+	return readEnvVars(newOptions(nil), i)
+}
+
+// as ReadEnvVars, but every env var name (derived or tagged) is prefixed: "APP1" reads APP1_HOST for Host
+func ReadEnvVarsPrefix(prefix string, i interface{}) {
+	if err := ReadEnvVarsPrefixErr(prefix, i); err != nil {
+		panic(err.Error())
+	}
+}
+
+// as ReadEnvVarsErr, but every env var name (derived or tagged) is prefixed
+func ReadEnvVarsPrefixErr(prefix string, i interface{}) error {
+	return readEnvVars(newOptions([]Option{WithPrefix(prefix)}), i)
+}
+
+// as ReadEnvVars, but the values are looked up in src instead of the environment
+func ReadEnvVarsFrom(src map[string]string, i interface{}) {
+	if err := ReadEnvVarsFromErr(src, i); err != nil {
+		panic(err.Error())
+	}
+}
+
+// as ReadEnvVarsErr, but the values are looked up in src instead of the environment
+func ReadEnvVarsFromErr(src map[string]string, i interface{}) error {
+	return readEnvVars(newOptions([]Option{FromMap(src)}), i)
+}
 
-	// Override default values with environment variables
-	for i := 0; i < v.NumField(); i++ {
-		getEnvVal(strings.ToUpper(t.Field(i).Name), v.Field(i))
+// lookupFn -- where env var values come from, os.LookupEnv or a map
+type lookupFn func(name string) (string, bool)
+
+// environMap -- the environment as a map
+func environMap() map[string]string {
+	vars := map[string]string{}
+	for _, kv := range os.Environ() {
+		if eq := strings.Index(kv, "="); eq > 0 {
+			vars[kv[:eq]] = kv[eq+1:]
+		}
+	}
+	return vars
+}
+
+// mapLookup -- a lookupFn reading from the map
+func mapLookup(src map[string]string) lookupFn {
+	return func(name string) (string, bool) {
+		v, ok := src[name]
+		return v, ok
+	}
+}
+
+// readEnvVars -- walk the fields of the structure, looking up each env var as the options say
+func readEnvVars(o *options, i interface{}) error {
+	v, err := structPtr("ReadEnvVars", i)
+	if err != nil {
+		return err
+	}
+	missing := []string{}
+	readers := map[string]string{} // the field path reading each env var name, to catch two doing so
+	var read walkFn
+	read = func(tags fieldTags, path string, field reflect.Value) error {
+		for _, name := range o.names(&tags) { // alternate names too, any of them may be the one read
+			if other, dup := readers[name]; dup && other != path {
+				return fmt.Errorf("ReadEnvVars: fields %s and %s both read %s", other, path, name)
+			}
+			readers[name] = path
+		}
+		switch {
+		case tags.format != "":
+		case field.Kind() == reflect.Map:
+			set := anyPrefixed(o.vars(), tags.mapPrefix())
+			o.track(path, set)
+			if !set && tags.required {
+				missing = append(missing, fmt.Sprintf("%s* (field %s)", tags.mapPrefix(), path))
+			}
+			if err := setEnvMap(o, tags, field); err != nil {
+				return o.fail(path, err)
+			}
+			return o.checkNotEmpty(tags, path, field)
+		case structSlice(field.Type()):
+			if tags.required && !anyPrefixed(o.vars(), tags.name+"_0_") {
+				missing = append(missing, fmt.Sprintf("%s_0_* (field %s)", tags.name, path))
+			}
+			if err := readStructSlice(o, tags, path, field, read); err != nil {
+				return err
+			}
+			return o.checkNotEmpty(tags, path, field)
+		case tags.presence:
+			if field.Kind() != reflect.Bool {
+				return o.fail(path, fmt.Errorf("presence tag on %v field for %s, only for bools", field.Type(), tags.name))
+			}
+			set := o.present(&tags)
+			o.track(path, set)
+			if set || !o.keep {
+				field.SetBool(set)
+			}
+			return nil
+		}
+		envVal, err := o.find(&tags)
+		if err != nil {
+			return o.fail(path, err)
+		}
+		o.track(path, envVal != "")
+		if envVal == "" {
+			if o.onMissing != nil {
+				o.onMissing(path, tags.name)
+			}
+			if tags.required {
+				missing = append(missing, fmt.Sprintf("%s (field %s)", tags.name, path))
+			}
+			if !o.keep || field.IsZero() {
+				envVal = tags.def // not set, try any `default:"..."` tag
+			}
+		}
+		if tags.expand {
+			if envVal, err = o.expand(tags.name, envVal); err != nil {
+				return o.fail(path, err)
+			}
+		}
+		if err := setEnvVal(tags, envVal, field); err != nil {
+			if tags.secret {
+				err = redact(err, envVal)
+			}
+			if err := o.fail(path, err); err != nil {
+				return err
+			}
+			if envVal != tags.def {
+				// lenient, so as if it wasn't set
+				if err := setEnvVal(tags, tags.def, field); err != nil {
+					return o.fail(path, err)
+				}
+			}
+		}
+		return o.checkNotEmpty(tags, path, field)
+	}
+	if err := walkStruct(o, o.prefix, "", v, read); err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("ReadEnvVars: missing required env vars: %s", strings.Join(missing, ", "))
+	}
+	if v, ok := i.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("ReadEnvVars: %w", err)
+		}
+	}
+	return nil
+}
+
+// structPtr -- the struct i points to, or an error (from who) if i isn't a pointer to a struct
+func structPtr(who string, i interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(i)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("%s: given %T, not a pointer to a struct", who, i)
+	}
+	return v.Elem(), nil
+}
+
+// Validator -- a structure implementing this has Validate called once the readers have filled it in,
+// for checks beyond the tags (START must be before END...), its error is the reader's
+type Validator interface {
+	Validate() error
+}
+
+// walkFn -- what walkStruct calls for each field
+type walkFn func(tags fieldTags, path string, field reflect.Value) error
+
+// walkStruct -- call fn for each exported field of v (named as the options say), recursing into nested structs: a nested struct's
+// env var names are prefixed with its own name (Database.Host reads DATABASE_HOST) while embedded
+// structs are flattened as is, any of their fields with the same env var name as an outer one left to it
+// (as Go's promoted fields are);  fn gets the field's tags with the final env var name and its Go field path
+func walkStruct(o *options, prefix, path string, v reflect.Value, fn walkFn) error {
+	return walkFields(o, prefix, path, v, fn, nil)
+}
+
+// walkFields -- walkStruct's work, skipping the fields whose env var names an outer struct has in shadowed
+func walkFields(o *options, prefix, path string, v reflect.Value, fn walkFn, shadowed map[string]bool) error {
+	fields := structPlan(v.Type(), o.snake)
+	outer := map[string]bool{} // the names shadowing those of any embedded structs
+	for name := range shadowed {
+		outer[name] = true
+	}
+	for _, wf := range fields {
+		if !wf.isStruct {
+			outer[prefix+wf.tags.name] = true
+		}
+	}
+
+	for _, wf := range fields {
+		f, tags, field := wf.f, wf.tags, v.FieldByIndex(wf.f.Index)
+		if wf.isStruct {
+			sub, inner := prefix, map[string]bool(nil)
+			if f.Anonymous {
+				inner = outer // flattened, so what's named here wins
+			} else {
+				sub += tags.name + "_"
+			}
+			if field.Kind() == reflect.Ptr {
+				if err := walkEmbedded(o, sub, path+f.Name+".", field, fn, inner); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := walkFields(o, sub, path+f.Name+".", field, fn, inner); err != nil {
+				return err
+			}
+			continue
+		}
+		tags.prefix = prefix
+		tags.name = prefix + tags.name
+		if shadowed[tags.name] {
+			continue // an outer struct's field has the name
+		}
+		if err := fn(tags, path+f.Name, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkEmbedded -- walk an embedded *Struct as walkFields does a struct:  a nil one is walked in a new
+// struct, set in the field only if left holding anything (as pointer fields are only allocated when
+// read), unless the options skip them or it can't be set (a nil *common, unexported)
+func walkEmbedded(o *options, prefix, path string, field reflect.Value, fn walkFn, shadowed map[string]bool) error {
+	if !field.IsNil() {
+		if o.copies && field.CanSet() {
+			// shared with the defaults (or the structure reread), so read into a copy leaving theirs alone
+			p := reflect.New(field.Type().Elem())
+			p.Elem().Set(field.Elem())
+			field.Set(p)
+		}
+		return walkFields(o, prefix, path, field.Elem(), fn, shadowed)
+	}
+	if o.skipNil || !field.CanSet() {
+		return nil
+	}
+	p := reflect.New(field.Type().Elem())
+	if err := walkFields(o, prefix, path, p.Elem(), fn, shadowed); err != nil {
+		return err
+	}
+	if !p.Elem().IsZero() {
+		field.Set(p)
+	}
+	return nil
+}
+
+// walkField -- a field walkFields visits, with its tags (the name not yet prefixed)
+type walkField struct {
+	f        reflect.StructField
+	tags     fieldTags
+	isStruct bool
+}
+
+// planKey -- what a struct's plan depends on
+type planKey struct {
+	t     reflect.Type
+	snake bool
+}
+
+// fieldPlans -- the []walkField of each struct type read, so the fields & tags are only gone over once
+var fieldPlans sync.Map
+
+// structPlan -- the fields of the struct type walkFields visits, from fieldPlans once worked out
+func structPlan(t reflect.Type, snake bool) []walkField {
+	key := planKey{t, snake}
+	if plan, ok := fieldPlans.Load(key); ok {
+		return plan.([]walkField)
+	}
+	plan := []walkField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tags := getTags(f)
+		isStruct := (nested(f.Type) || f.Anonymous && f.Type.Kind() == reflect.Ptr && nested(f.Type.Elem())) && tags.format == ""
+		if f.PkgPath != "" && !(f.Anonymous && isStruct) {
+			continue // non-cap names are private and aren't touched
+		}
+		if f.Tag.Get("env") == "-" {
+			continue // as are any tagged `env:"-"`
+		}
+		if !tags.tagged && snake {
+			tags.name = snakeCase(f.Name)
+		}
+		plan = append(plan, walkField{f, tags, isStruct})
+	}
+	actual, _ := fieldPlans.LoadOrStore(key, plan)
+	return actual.([]walkField)
+}
+
+// readStructSlice -- fill a []struct field from indexed env vars, each element read as a nested struct:
+// Servers []Server `env:"SERVER"` reads SERVER_0_HOST, SERVER_0_PORT, SERVER_1_HOST... up to the first
+// index with no env vars at all
+func readStructSlice(o *options, tags fieldTags, path string, field reflect.Value, fn walkFn) error {
+	vars := o.vars()
+	elems := reflect.MakeSlice(field.Type(), 0, 0)
+	for n := 0; ; n++ {
+		prefix := fmt.Sprintf("%s_%d_", tags.name, n)
+		if !anyPrefixed(vars, prefix) {
+			break
+		}
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := walkStruct(o, prefix, fmt.Sprintf("%s[%d].", path, n), elem, fn); err != nil {
+			return err
+		}
+		elems = reflect.Append(elems, elem)
+	}
+	if elems.Len() > 0 {
+		field.Set(elems)
+	}
+	return nil
+}
+
+// anyPrefixed -- if any of the vars named prefix + something has a value (not blank, which reads as unset)
+func anyPrefixed(vars map[string]string, prefix string) bool {
+	for name, envVal := range vars {
+		if len(name) > len(prefix) && strings.HasPrefix(name, prefix) && !blank(envVal) {
+			return true
+		}
+	}
+	return false
+}
+
+// structSlice -- true for a slice of structs read element by element from indexed env vars
+func structSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && nested(t.Elem())
+}
+
+// nested -- true if the type is a struct to descend into, not one read from a single env var
+func nested(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct || t == timeType || t == ipNetType || t == urlType || nullTypes[t] {
+		return false
+	}
+	_, parsed := parserFor(t)
+	return !parsed
+}
+
+// fieldTags -- what the struct tags ask of a field
+type fieldTags struct {
+	name     string   // env var to read
+	field    string   // Go field name
+	prefix   string   // prefix of name, from a prefixed read or nesting
+	tagged   bool     // name is from an `env:"NAME"` tag
+	alts     []string // `env:"NAME,OLD_NAME"` names to try, in order, if NAME isn't set
+	def      string   // `default:"..."` value
+	required bool     // `env:"NAME,required"` or `required:"true"`
+	sep      string   // `sep:","` splits slices on that instead of envSep
+	trim     bool     // `trim:"true"` trims whitespace around the value and each slice element
+	unquote  bool     // `trim:"quotes"` also strips a layer of matching '' or "" quotes
+	nonEmpty bool     // `env:"NAME,nonempty"` drops empty slice elements
+	secret   bool     // `env:"NAME,secret"` the value is hidden in errors, Snapshot & Dump
+	expand   bool     // `expand:"true"` replaces ${VAR} & $VAR in the value (or default) with their values
+	presence bool     // `env:"NO_COLOR,presence"` a bool that is true if the env var is set at all
+	notEmpty bool     // `notEmpty:"true"` errors if the string, slice or map is still empty once read
+	raw      bool     // `env:"NAME,raw"` keeps a `sep` tag's slice elements as they are
+	layout   string   // `layout:"2006-01-02"` for time.Time, default time.RFC3339
+	min, max string   // `min:"1" max:"64"` limits for int & uint fields
+	as       string   // `as:"int"` the type to read an interface{} field as, see asTypes
+	base     string   // `base:"16"` for ints & uints, default 0 (Go literals), and *big.Int, default 10
+	pct      bool     // `pct:"true"` int & uint fields take an optional trailing '%': 75%
+	bytes    bool     // `bytes:"true"` int & uint fields take sizes: 10MB, 1.5GiB
+	oneof    []string // `oneof:"debug info warn error"` allowed string values
+	oneofCI  bool     // `oneof:"debug info,ci"` matches them case-insensitively
+	encoding string   // `encoding:"hex"` or "base64" for []byte, default raw
+	scheme   []string // `scheme:"https"` (or "http https") the schemes allowed for url.URL
+	format   string   // `format:"json"` unmarshals the value into the field, whatever its type
+	gather   string   // `prefix:"LABEL_"` for maps, default the name + '_'
+	lowerKey bool     // `prefix:"LABEL_,lower"` lower cases the map keys
+}
+
+// the field's slice separator
+func (tags fieldTags) joiner() string {
+	if tags.sep == "" {
+		return envSep
+	}
+	return tags.sep
+}
+
+// split a slice value with the field's separator, a `sep` tag's elements are trimmed with empty ones
+// dropped unless `env:"NAME,raw"`
+func (tags fieldTags) split(envVal string) []string {
+	parts := strings.Split(envVal, tags.joiner())
+	tidy := tags.sep != "" && !tags.raw
+	if tags.trim || tidy {
+		for n := range parts {
+			parts[n] = tags.clean(parts[n])
+		}
+	}
+	if tags.nonEmpty || tidy {
+		if parts = nonEmpty(parts); len(parts) == 0 {
+			return nil
+		}
 	}
+	return parts
+}
+
+// clean -- trim the whitespace, and quotes if asked, from around a value or slice element
+func (tags fieldTags) clean(s string) string {
+	s = strings.TrimSpace(s)
+	if tags.unquote && len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+	}
+	return s
+}
+
+// setElems -- convert each of the parts into an element of v, the value of its own named NAME[n]
+func (tags fieldTags) setElems(parts []string, v reflect.Value) error {
+	elem := fieldTags{base: tags.base, layout: tags.layout}
+	for n, p := range parts {
+		elem.name = fmt.Sprintf("%s[%d]", tags.name, n)
+		if blank(p) {
+			// not left zero as an unset value would be, an error as for []int
+			return convErr("", v.Index(n), elem.name, p, errors.New("empty element"))
+		}
+		if err := setEnvVal(elem, p, v.Index(n)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// intBase -- the `base` tag, def if none
+func (tags fieldTags) intBase(def int) (int, error) {
+	if tags.base == "" {
+		return def, nil
+	}
+	base, err := strconv.Atoi(tags.base)
+	if err != nil || base != 0 && (base < 2 || base > big.MaxBase) {
+		return 0, fmt.Errorf("Illegal base tag %q for %s", tags.base, tags.name)
+	}
+	return base, nil
+}
+
+// mapPrefix -- the prefix of the env vars a map field gathers
+func (tags fieldTags) mapPrefix() string {
+	if tags.gather == "" {
+		return tags.name + "_"
+	}
+	return tags.prefix + tags.gather
+}
+
+// intRange -- check v against any min / max tags
+func (tags fieldTags) intRange(envVal string, v int64) error {
+	if tags.min != "" {
+		min, err := strconv.ParseInt(tags.min, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Illegal min tag %q for %s", tags.min, tags.name)
+		}
+		if v < min {
+			return fmt.Errorf("Value %s=%q below min %s", tags.name, envVal, tags.min)
+		}
+	}
+	if tags.max != "" {
+		max, err := strconv.ParseInt(tags.max, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Illegal max tag %q for %s", tags.max, tags.name)
+		}
+		if v > max {
+			return fmt.Errorf("Value %s=%q above max %s", tags.name, envVal, tags.max)
+		}
+	}
+	return nil
+}
+
+// uintRange -- check v against any min / max tags
+func (tags fieldTags) uintRange(envVal string, v uint64) error {
+	if tags.min != "" {
+		min, err := strconv.ParseUint(tags.min, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Illegal min tag %q for %s", tags.min, tags.name)
+		}
+		if v < min {
+			return fmt.Errorf("Value %s=%q below min %s", tags.name, envVal, tags.min)
+		}
+	}
+	if tags.max != "" {
+		max, err := strconv.ParseUint(tags.max, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Illegal max tag %q for %s", tags.max, tags.name)
+		}
+		if v > max {
+			return fmt.Errorf("Value %s=%q above max %s", tags.name, envVal, tags.max)
+		}
+	}
+	return nil
+}
+
+// allowed -- check a string value against any oneof tag
+func (tags fieldTags) allowed(envVal string) error {
+	if len(tags.oneof) == 0 {
+		return nil
+	}
+	for _, ok := range tags.oneof {
+		if envVal == ok || (tags.oneofCI && strings.EqualFold(envVal, ok)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Value %s=%q not one of: %s", tags.name, envVal, strings.Join(tags.oneof, ", "))
+}
+
+// checkScheme -- a url.URL value needs one of the schemes in any scheme tag
+func (tags fieldTags) checkScheme(envVal string, u *url.URL) error {
+	if len(tags.scheme) == 0 {
+		return nil
+	}
+	for _, scheme := range tags.scheme {
+		if strings.EqualFold(u.Scheme, scheme) {
+			return nil
+		}
+	}
+	return fmt.Errorf("Value %s=%q needs scheme: %s", tags.name, envVal, strings.Join(tags.scheme, ", "))
+}
+
+// decode -- a []byte value as the encoding tag says
+func (tags fieldTags) decode(envVal string) ([]byte, error) {
+	switch tags.encoding {
+	case "":
+		return []byte(envVal), nil
+	case "hex":
+		return hex.DecodeString(envVal)
+	case "base64":
+		return base64.StdEncoding.DecodeString(envVal)
+	}
+	return nil, fmt.Errorf("unknown encoding %q", tags.encoding)
+}
+
+// encode -- a []byte value as the encoding tag says
+func (tags fieldTags) encode(b []byte) (string, error) {
+	switch tags.encoding {
+	case "":
+		return string(b), nil
+	case "hex":
+		return hex.EncodeToString(b), nil
+	case "base64":
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+	return "", fmt.Errorf("unknown encoding %q", tags.encoding)
+}
+
+// getTags -- parse the tags of a field, the env var name is the `env:"NAME"` tag
+// verbatim, else the upper-cased field name; options follow the name: `env:"NAME,required"`
+func getTags(f reflect.StructField) fieldTags {
+	tags := fieldTags{
+		def:    f.Tag.Get("default"),
+		sep:    f.Tag.Get("sep"),
+		layout: f.Tag.Get("layout"),
+		min:    f.Tag.Get("min"),
+		max:    f.Tag.Get("max"),
+
+		as:       f.Tag.Get("as"),
+		base:     f.Tag.Get("base"),
+		encoding: f.Tag.Get("encoding"),
+		format:   f.Tag.Get("format"),
+		scheme:   strings.Fields(f.Tag.Get("scheme")),
+	}
+	if gather := f.Tag.Get("prefix"); gather != "" {
+		list := strings.Split(gather, ",")
+		tags.gather = list[0]
+		for _, opt := range list[1:] {
+			if opt == "lower" {
+				tags.lowerKey = true
+			}
+		}
+	}
+	if oneof := f.Tag.Get("oneof"); oneof != "" {
+		list := strings.Split(oneof, ",")
+		tags.oneof = strings.Fields(list[0])
+		for _, opt := range list[1:] {
+			if opt == "ci" {
+				tags.oneofCI = true
+			}
+		}
+	}
+	opts := strings.Split(f.Tag.Get("env"), ",")
+	tags.field, tags.name, tags.tagged = f.Name, opts[0], opts[0] != ""
+	if !tags.tagged {
+		tags.name = strings.ToUpper(f.Name)
+	}
+	for _, opt := range opts[1:] {
+		switch opt {
+		case "required":
+			tags.required = true
+		case "nonempty":
+			tags.nonEmpty = true
+		case "raw":
+			tags.raw = true
+		case "presence":
+			tags.presence = true
+		case "secret":
+			tags.secret = true
+		default:
+			if opt != "" {
+				tags.alts = append(tags.alts, opt) // not an option, an alternate name
+			}
+		}
+	}
+	if req, ok := parseBool(f.Tag.Get("required")); ok && req {
+		tags.required = true
+	}
+	tags.pct, _ = parseBool(f.Tag.Get("pct"))
+	tags.bytes, _ = parseBool(f.Tag.Get("bytes"))
+	tags.expand, _ = parseBool(f.Tag.Get("expand"))
+	tags.notEmpty, _ = parseBool(f.Tag.Get("notEmpty"))
+	if trim := f.Tag.Get("trim"); trim == "quotes" {
+		tags.trim, tags.unquote = true, true
+	} else {
+		tags.trim, _ = parseBool(trim)
+	}
+	return tags
+}
+
+// listSep -- the path list separator for the OS, only windows differs
+func listSep(goos string) string {
+	if goos == "windows" {
+		return ";"
+	}
+	return ":"
 }
 
 // getEnv -- run as variable assignment to be assured it is run before all 'init' methods; some which may call into here
 func getEnv() bool {
-	ReadEnvVars(&env)
+	var e envFacts
+	o := newOptions([]Option{WithPrefix("")})
+	o.onMissing = nil // the package's own aren't the caller's config
+	if err := readEnvVars(o, &e); err != nil {
+		panic(err.Error()) // as ReadEnvVars, but never with a global prefix or OnMissing
+	}
 
 	// validate we have some values
-	if env.Host == "" {
-		env.Host = runtime.GOOS
+	if e.Host == "" {
+		e.Host = goos
 	}
-	if env.User == "" {
+	if e.User == "" {
 		// try Windows 'USERNAME'
-		getEnvVal("USERNAME", reflect.ValueOf(&env).Elem().FieldByName("User"))
+		getEnvVal("USERNAME", reflect.ValueOf(&e).Elem().FieldByName("User"))
+	}
+	e.Path = nonEmpty(e.Path)
+	if e.Home == "" {
+		// try Windows 'USERPROFILE', then whatever the os package can find
+		getEnvVal("USERPROFILE", reflect.ValueOf(&e).Elem().FieldByName("Home"))
+		if e.Home == "" {
+			e.Home, _ = os.UserHomeDir()
+		}
 	}
 
+	// Go's own defaults, as 'go env' gives them (so after Home, for ~/go)
+	if e.GoRoot == "" {
+		e.GoRoot = goRootOf(e.Path)
+	}
+	if e.GoPath = nonEmpty(e.GoPath); len(e.GoPath) == 0 && e.Home != "" {
+		e.GoPath = []string{filepath.Join(e.Home, "go")}
+	}
+	if e.GoBin == "" && len(e.GoPath) > 0 {
+		e.GoBin = filepath.Join(e.GoPath[0], "bin")
+	}
+	// the system's own handling goes by goos, not Host:  tcsh exports HOST as the machine's name
+	e.wsl = detectWSL(goos)
+	e.container = detectContainer(goos)
+	e.rawUser = e.User
+	if goos == "windows" {
+		// drop any leading 'DOMAIN\'
+		if n := strings.LastIndex(e.User, "\\"); n >= 0 {
+			e.User = e.User[n+1:]
+		}
+	}
+	if goos == "windows" {
+		// PowerShell adds the user's own modules (Documents\PowerShell\Modules) to 'PSModulePath', its
+		// machine wide entries are set in cmd too, otherwise go by 'ComSpec'
+		if userModules(os.Getenv("PSModulePath"), e.Home) {
+			e.Shell = "powershell"
+		} else if cs := os.Getenv("ComSpec"); cs != "" {
+			cs = cs[strings.LastIndexAny(cs, `\/`)+1:] // not filepath.Base, '\' is only a separator on windows
+			e.Shell = strings.TrimSuffix(strings.ToLower(cs), ".exe")
+		}
+	} else if e.Shell != "" {
+		e.Shell = filepath.Base(e.Shell)
+	}
+
+	e.zone = time.Local
+	if tz := strings.TrimPrefix(e.TZ, ":"); tz != "" {
+		// ':' is the C library's "a file", LoadLocation takes the names & paths alike
+		if loc, err := time.LoadLocation(tz); err == nil {
+			e.zone = loc
+		}
+	}
+
+	e.cpus = numCPU()
+	if n, err := strconv.Atoi(strings.TrimSpace(os.Getenv("GOMAXPROCS"))); err == nil && n > 0 && n < e.cpus {
+		e.cpus = n
+	}
+
+	e.read = true
+	envMu.Lock()
+	env = e
+	envMu.Unlock()
 	return true
 }
 
-// read in env vars for element
-func getEnvVal(envname string, field reflect.Value) {
-	envVal := os.Getenv(envname)
+// userModules -- if any of the ';' separated PSModulePath dirs is under home, as PowerShell's user one is
+func userModules(psModulePath, home string) bool {
+	home = strings.TrimRight(home, `\/`)
+	if home == "" {
+		return false
+	}
+	for _, dir := range strings.Split(psModulePath, ";") {
+		if len(dir) > len(home) && strings.EqualFold(dir[:len(home)], home) && strings.ContainsRune(`\/`, rune(dir[len(home)])) {
+			return true
+		}
+	}
+	return false
+}
+
+// goRootOf -- the GOROOT of the first go on the path, the directory above its bin, following any
+// link to it (/usr/bin/go -> /usr/lib/go/bin/go), or "" if there's none
+func goRootOf(path []string) string {
+	name := "go"
+	if goos == "windows" {
+		name = "go.exe"
+	}
+	for _, dir := range path {
+		p := filepath.Join(dir, name)
+		if fi, err := os.Stat(p); err != nil || !fi.Mode().IsRegular() {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(p); err == nil {
+			p = real
+		}
+		return filepath.Dir(filepath.Dir(p))
+	}
+	return ""
+}
+
+// nonEmpty -- drop the empty strings
+func nonEmpty(list []string) []string {
+	kept := list[:0]
+	for _, s := range list {
+		if s != "" {
+			kept = append(kept, s)
+		}
+	}
+	return kept
+}
+
+// emptyValue -- a string that is blank, or a slice, array or map with no elements
+func emptyValue(field reflect.Value) bool {
+	switch field.Kind() {
+	case reflect.String:
+		return blank(field.String())
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return field.Len() == 0
+	case reflect.Ptr:
+		return field.IsNil() || emptyValue(field.Elem())
+	}
+	return false
+}
+
+// blank -- an empty or whitespace only value, which every reader takes as unset
+func blank(envVal string) bool {
+	return strings.TrimSpace(envVal) == ""
+}
+
+// parseBool accepts (case-insensitive):  1/0, true/false, yes/no, on/off
+func parseBool(s string) (bool, bool) {
+	switch strings.ToLower(s) {
+	case "1", "true", "yes", "on":
+		return true, true
+	case "0", "false", "no", "off":
+		return false, true
+	}
+	return false, false
+}
+
+// byteUnits -- the multipliers of the size suffixes, upper cased:  K, KB are 1000, KiB is 1024...
+var byteUnits = map[string]uint64{
+	"": 1, "B": 1,
+	"K": 1e3, "KB": 1e3, "KIB": 1 << 10,
+	"M": 1e6, "MB": 1e6, "MIB": 1 << 20,
+	"G": 1e9, "GB": 1e9, "GIB": 1 << 30,
+	"T": 1e12, "TB": 1e12, "TIB": 1 << 40,
+	"P": 1e15, "PB": 1e15, "PIB": 1 << 50,
+}
+
+// parseSize -- a byte count from a size like 512, 10MB, 64 KiB or 1.5G, erroring if it needs more than bits
+func parseSize(s string, bits int) (uint64, error) {
+	num, unit := s, ""
+	if n := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' }); n >= 0 {
+		num, unit = s[:n], strings.TrimSpace(s[n:])
+	}
+	mul, ok := byteUnits[strings.ToUpper(unit)]
+	if !ok {
+		return 0, fmt.Errorf("unknown size suffix %q", unit)
+	}
+	limit := uint64(1)<<uint(bits) - 1
+	if bits >= 64 {
+		limit = math.MaxUint64
+	}
+	if !strings.Contains(num, ".") {
+		v, err := strconv.ParseUint(num, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		if v > limit/mul {
+			return 0, strconv.ErrRange
+		}
+		return v * mul, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f*float64(mul) >= float64(limit) {
+		return 0, strconv.ErrRange
+	}
+	return uint64(f * float64(mul)), nil
+}
+
+// asTypes -- what an interface{} field's `as` tag can name, with no tag it is the string
+var asTypes = map[string]reflect.Type{
+	"":         reflect.TypeOf(""),
+	"string":   reflect.TypeOf(""),
+	"int":      reflect.TypeOf(0),
+	"int64":    reflect.TypeOf(int64(0)),
+	"uint":     reflect.TypeOf(uint(0)),
+	"uint64":   reflect.TypeOf(uint64(0)),
+	"float64":  reflect.TypeOf(0.0),
+	"bool":     reflect.TypeOf(false),
+	"duration": durationType,
+	"time":     timeType,
+	"[]string": reflect.TypeOf([]string(nil)),
+	"[]int":    reflect.TypeOf([]int(nil)),
+}
+
+// convErr -- a ParseError for the failed conversion, the reader fills in the field
+func convErr(what string, field reflect.Value, envname, envVal string, err error) error {
+	return &ParseError{EnvName: envname, Value: envVal, Kind: field.Kind(), Err: err, what: what}
+}
+
+// fill a map[string]T element from all the env vars starting with its prefix, keyed by the rest of their names;
+// the values are all converted before any is added, so a bad one leaves the field as it was
+func setEnvMap(o *options, tags fieldTags, field reflect.Value) error {
+	gather := tags.mapPrefix()
+	read := reflect.MakeMap(field.Type())
+	for name, envVal := range o.vars() {
+		if !strings.HasPrefix(name, gather) || len(name) == len(gather) || blank(envVal) {
+			continue // not one of the map's, or unset as blank values are
+		}
+		if field.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("Unsupported type %v for %s=%q", field.Type(), name, envVal)
+		}
+		o.use(name)
+		key := name[len(gather):]
+		if tags.lowerKey {
+			key = strings.ToLower(key)
+		}
+		// each value converted as a field of the element type would be:  map[string]int, map[string]bool...
+		elem := reflect.New(field.Type().Elem()).Elem()
+		if err := setEnvVal(fieldTags{name: name, sep: tags.sep, base: tags.base, layout: tags.layout}, envVal, elem); err != nil {
+			return err
+		}
+		read.SetMapIndex(reflect.ValueOf(key).Convert(field.Type().Key()), elem)
+	}
+	if read.Len() == 0 {
+		return nil
+	}
+	// any entries already there (from defaults) are copied in, not added to:  the map may be shared
+	for iter := field.MapRange(); iter.Next(); {
+		if !read.MapIndex(iter.Key()).IsValid() {
+			read.SetMapIndex(iter.Key(), iter.Value())
+		}
+	}
+	field.Set(read)
+	return nil
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	ipType       = reflect.TypeOf(net.IP(nil))
+	ipNetType    = reflect.TypeOf(net.IPNet{})
+	ipNetsType   = reflect.TypeOf([]net.IPNet(nil))
+	urlType      = reflect.TypeOf(url.URL{})
+	fileModeType = reflect.TypeOf(os.FileMode(0))
+
+	// the database/sql optional types, read as their value & Valid if set
+	nullTypes = map[reflect.Type]bool{
+		reflect.TypeOf(sql.NullString{}):  true,
+		reflect.TypeOf(sql.NullInt64{}):   true,
+		reflect.TypeOf(sql.NullInt32{}):   true,
+		reflect.TypeOf(sql.NullInt16{}):   true,
+		reflect.TypeOf(sql.NullByte{}):    true,
+		reflect.TypeOf(sql.NullBool{}):    true,
+		reflect.TypeOf(sql.NullFloat64{}): true,
+		reflect.TypeOf(sql.NullTime{}):    true,
+	}
+	bigIntType   = reflect.TypeOf((*big.Int)(nil))
+	bigFloatType = reflect.TypeOf((*big.Float)(nil))
+)
+
+// read in env vars for element, returns error if the value can't be converted
+func getEnvVal(envname string, field reflect.Value) error {
+	return setEnvVal(fieldTags{name: envname}, os.Getenv(envname), field)
+}
+
+// convert the value for the element, a blank value leaves the element untouched
+func setEnvVal(tags fieldTags, envVal string, field reflect.Value) error {
+	envname := tags.name
+	if tags.trim {
+		envVal = tags.clean(envVal)
+	}
+	if !blank(envVal) {
+		switch tags.format {
+		case "":
+		case "json":
+			if err := json.Unmarshal([]byte(envVal), field.Addr().Interface()); err != nil {
+				return convErr("json", field, envname, envVal, err)
+			}
+			return nil
+		default:
+			return fmt.Errorf("Unsupported format %q for %s", tags.format, envname)
+		}
+
+		if parse, ok := parserFor(field.Type()); ok {
+			return setParsed(parse, tags, envVal, field)
+		}
+		if nullTypes[field.Type()] {
+			// sql.NullString...:  the value into its first field, then Valid
+			if err := setEnvVal(tags, envVal, field.Field(0)); err != nil {
+				return err
+			}
+			field.FieldByName("Valid").SetBool(true)
+			return nil
+		}
+
+		// types needing more than their kind's conversion come first
+		switch field.Type() {
+		case durationType:
+			v, err := time.ParseDuration(envVal)
+			if err != nil {
+				return convErr("duration", field, envname, envVal, err)
+			}
+			field.SetInt(int64(v))
+			return nil
+		case timeType:
+			layout := tags.layout
+			if layout == "" {
+				layout = time.RFC3339
+			}
+			v, err := time.Parse(layout, envVal)
+			if err != nil {
+				return convErr("time", field, envname, envVal, err)
+			}
+			field.Set(reflect.ValueOf(v))
+			return nil
+		case ipType:
+			v := net.ParseIP(envVal)
+			if v == nil {
+				return convErr("IP", field, envname, envVal, errors.New("not an IP address"))
+			}
+			field.Set(reflect.ValueOf(v))
+			return nil
+		case urlType:
+			v, err := url.Parse(envVal)
+			if err != nil {
+				return convErr("URL", field, envname, envVal, err)
+			}
+			if err := tags.checkScheme(envVal, v); err != nil {
+				return err
+			}
+			field.Set(reflect.ValueOf(*v))
+			return nil
+		case ipNetType:
+			_, v, err := net.ParseCIDR(envVal)
+			if err != nil {
+				return convErr("CIDR", field, envname, envVal, err)
+			}
+			field.Set(reflect.ValueOf(*v))
+			return nil
+		case ipNetsType:
+			var v []net.IPNet
+			for n, p := range tags.split(envVal) {
+				_, ipNet, err := net.ParseCIDR(p)
+				if err != nil {
+					return convErr("CIDR", field, fmt.Sprintf("%s[%d]", envname, n), p, err)
+				}
+				v = append(v, *ipNet)
+			}
+			field.Set(reflect.ValueOf(v))
+			return nil
+		case bigIntType:
+			base, err := tags.intBase(10)
+			if err != nil {
+				return err
+			}
+			v, ok := new(big.Int).SetString(envVal, base)
+			if !ok {
+				return convErr("big.Int", field, envname, envVal, fmt.Errorf("not a base %d integer", base))
+			}
+			field.Set(reflect.ValueOf(v))
+			return nil
+		case bigFloatType:
+			v, ok := new(big.Float).SetString(envVal)
+			if !ok {
+				return convErr("big.Float", field, envname, envVal, errors.New("not a number"))
+			}
+			field.Set(reflect.ValueOf(v))
+			return nil
+		case fileModeType:
+			v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(envVal), "0o"), 8, 9) // the permission bits, 0777 at most
+			if err != nil {
+				return convErr("file mode", field, envname, envVal, err)
+			}
+			field.SetUint(v)
+			return nil
+		}
 
-	if len(envVal) > 0 {
 		switch field.Kind() {
 		case reflect.String:
-			field.Set(reflect.ValueOf(envVal))
-		case reflect.Int:
-			v, err := strconv.Atoi(envVal)
+			if err := tags.allowed(envVal); err != nil {
+				return err
+			}
+			field.SetString(envVal) // not Set, which panics for named types:  type Level string
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if tags.pct {
+				envVal = strings.TrimSuffix(envVal, "%")
+			}
+			if tags.bytes {
+				v, err := parseSize(envVal, field.Type().Bits()-1)
+				if err != nil {
+					return convErr("size", field, envname, envVal, err)
+				}
+				if err := tags.intRange(envVal, int64(v)); err != nil {
+					return err
+				}
+				field.SetInt(int64(v))
+				return nil
+			}
+			base, err := tags.intBase(0)
 			if err != nil {
-				panic("ReadEnvVars: Illegal atoi conversion")
+				return err
 			}
-			field.Set(reflect.ValueOf(v))
+			v, err := strconv.ParseInt(envVal, base, field.Type().Bits())
+			if err != nil {
+				return convErr("int", field, envname, envVal, err)
+			}
+			if err := tags.intRange(envVal, v); err != nil {
+				return err
+			}
+			field.SetInt(v)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if tags.pct {
+				envVal = strings.TrimSuffix(envVal, "%")
+			}
+			parse, what := strconv.ParseUint, "uint"
+			if tags.bytes {
+				parse, what = func(s string, _ int, bits int) (uint64, error) { return parseSize(s, bits) }, "size"
+			}
+			base, err := tags.intBase(0)
+			if err != nil {
+				return err
+			}
+			v, err := parse(envVal, base, field.Type().Bits())
+			if err != nil {
+				return convErr(what, field, envname, envVal, err)
+			}
+			if err := tags.uintRange(envVal, v); err != nil {
+				return err
+			}
+			field.SetUint(v)
+		case reflect.Bool:
+			v, ok := parseBool(envVal)
+			if !ok {
+				return convErr("bool", field, envname, envVal, errors.New("not a bool"))
+			}
+			field.SetBool(v)
+		case reflect.Float32, reflect.Float64:
+			v, err := strconv.ParseFloat(envVal, field.Type().Bits())
+			if err != nil {
+				return convErr("float", field, envname, envVal, err)
+			}
+			field.SetFloat(v)
+		case reflect.Complex64, reflect.Complex128:
+			v, err := strconv.ParseComplex(envVal, field.Type().Bits())
+			if err != nil {
+				return convErr("complex", field, envname, envVal, err)
+			}
+			field.SetComplex(v)
 		case reflect.Slice:
-			switch field.Type() {
-			case reflect.TypeOf([]string(nil)):
-				v := strings.Split(envVal, envSep)
-				field.Set(reflect.ValueOf(v))
+			// by the element's kind, with SetString... so named types work:  type Tags []string, []Level
+			switch field.Type().Elem().Kind() {
+			case reflect.String:
+				parts := tags.split(envVal)
+				v := reflect.Zero(field.Type()) // nil when nonempty dropped every element
+				if parts != nil {
+					v = reflect.MakeSlice(field.Type(), len(parts), len(parts))
+				}
+				for n, p := range parts {
+					v.Index(n).SetString(p)
+				}
+				field.Set(v)
+			case reflect.Uint8:
+				v, err := tags.decode(envVal)
+				if err != nil {
+					return convErr(tags.encoding, field, envname, envVal, err)
+				}
+				field.SetBytes(v)
+			case reflect.Int:
+				parts := tags.split(envVal)
+				v := reflect.Zero(field.Type()) // nil when nonempty dropped every element
+				if parts != nil {
+					v = reflect.MakeSlice(field.Type(), len(parts), len(parts))
+				}
+				base, err := tags.intBase(0)
+				if err != nil {
+					return err
+				}
+				for n, p := range parts {
+					i, err := strconv.ParseInt(p, base, 0)
+					if err != nil {
+						return convErr("int", field, fmt.Sprintf("%s[%d]", envname, n), p, err)
+					}
+					v.Index(n).SetInt(i)
+				}
+				field.Set(v)
 			default:
-				panic("ReadEnvVars: Unexpected type")
+				// any other element type ([]time.Duration, []float64...) converts each as a value of its own
+				parts := tags.split(envVal)
+				if parts == nil {
+					field.Set(reflect.Zero(field.Type()))
+					break
+				}
+				v := reflect.MakeSlice(field.Type(), len(parts), len(parts))
+				if err := tags.setElems(parts, v); err != nil {
+					return err
+				}
+				field.Set(v)
+			}
+		case reflect.Array:
+			parts := tags.split(envVal)
+			if len(parts) != field.Len() {
+				return convErr("array", field, envname, envVal, fmt.Errorf("%d elements, not %d", len(parts), field.Len()))
+			}
+			v := reflect.New(field.Type()).Elem() // so a bad element leaves the field as it was
+			if err := tags.setElems(parts, v); err != nil {
+				return err
+			}
+			field.Set(v)
+		case reflect.Ptr:
+			// only allocated when there is a value, so nil tells the caller the env var wasn't set
+			p := reflect.New(field.Type().Elem())
+			if err := setEnvVal(tags, envVal, p.Elem()); err != nil {
+				return err
+			}
+			field.Set(p)
+		case reflect.Interface:
+			if field.NumMethod() > 0 {
+				return fmt.Errorf("Unsupported type %v for %s=%q", field.Type(), envname, envVal)
+			}
+			t, ok := asTypes[tags.as]
+			if !ok {
+				return fmt.Errorf("Illegal as tag %q for %s", tags.as, envname)
+			}
+			v := reflect.New(t).Elem()
+			if err := setEnvVal(tags, envVal, v); err != nil {
+				return err
 			}
+			field.Set(v)
 		default:
-			panic("ReadEnvVars: Unexpected kind")
+			return fmt.Errorf("Unsupported kind %v (type %v) for %s=%q", field.Kind(), field.Type(), envname, envVal)
 		}
 	}
+	return nil
 }
@@ -0,0 +1,573 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchCfg -- a typical structure: tags, a nested struct, a list & a duration
+type benchCfg struct {
+	Name string   `oneof:"a b c"`
+	Tags []string `sep:","`
+	DB   struct {
+		Host string `env:"HOST,DB_HOST" default:"localhost"`
+		Port int    `default:"5432" min:"1" max:"65535"`
+	}
+	Level   string `env:"LOG_LEVEL,required"`
+	Timeout time.Duration
+}
+
+var benchSrc = map[string]string{"NAME": "a", "TAGS": "x, y", "DB_PORT": "1", "LOG_LEVEL": "info", "TIMEOUT": "1s"}
+
+// BenchmarkReadEnvVars -- cached is every read after the first, uncached drops the field plans
+// before each read, as every read was before they were kept
+func BenchmarkReadEnvVars(b *testing.B) {
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var c benchCfg
+			if err := ReadEnvVarsFromErr(benchSrc, &c); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fieldPlans.Range(func(key, _ interface{}) bool {
+				fieldPlans.Delete(key)
+				return true
+			})
+			var c benchCfg
+			if err := ReadEnvVarsFromErr(benchSrc, &c); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGoDefaults(t *testing.T) {
+	t.Cleanup(func() { Reset("") }) // registered first, so run once the env vars are put back
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "bin", "go"), nil, 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", filepath.Join(root, "bin"))
+	t.Setenv("GOROOT", "")
+	t.Setenv("GOPATH", "")
+	t.Setenv("GOBIN", "")
+	t.Setenv("HOME", "/home/bob")
+	Reset("")
+	if GoRoot() != root {
+		t.Errorf("GoRoot() = %q, want %q", GoRoot(), root)
+	}
+	if gp := GoPath(); len(gp) != 1 || gp[0] != filepath.Join("/home/bob", "go") {
+		t.Errorf("GoPath() = %q", gp)
+	}
+
+	// no HOME, as on windows:  the defaults follow USERPROFILE
+	t.Setenv("HOME", "")
+	t.Setenv("USERPROFILE", `C:\Users\bob`)
+	Reset("windows")
+	want := filepath.Join(`C:\Users\bob`, "go")
+	if gp := GoPath(); len(gp) != 1 || gp[0] != want {
+		t.Errorf("windows GoPath() = %q, want [%q]", gp, want)
+	}
+	if GoBin() != filepath.Join(want, "bin") {
+		t.Errorf("windows GoBin() = %q", GoBin())
+	}
+}
+
+func TestLenientLeavesZero(t *testing.T) {
+	var c struct {
+		Coords [3]int
+		Labels map[string]int
+		Port   int `default:"80"`
+	}
+	for name, envVal := range map[string]string{"COORDS": "1:x:3", "LABELS_a": "1", "LABELS_b": "bad", "LABELS_c": "3", "PORT": "http"} {
+		t.Setenv(name, envVal)
+	}
+	errs := LenientRead(&c)
+	if len(errs) != 3 {
+		t.Errorf("LenientRead errors = %v, want 3", errs)
+	}
+	if c.Coords != [3]int{} || c.Labels != nil || c.Port != 80 {
+		t.Errorf("LenientRead = %+v, want the zero values & default", c)
+	}
+}
+
+// TestAccessorsRefresh -- the accessors read while Refresh replaces what they read, run it with -race
+func TestAccessorsRefresh(t *testing.T) {
+	t.Cleanup(func() { Refresh() })
+	t.Setenv("TERM", "start")
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for n := 0; n < 8; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				_ = Host() + User() + RawUser() + HomeDir() + Shell() + Terminal() + GoRoot() + GoBin() + Locale()
+				_ = len(Path()) + len(GoPath()) + NumCPU()
+				_ = IsLinux() || IsWindows() || IsDarwin() || IsUnix() || Ready()
+				_ = TimeZone()
+			}
+		}()
+	}
+	for n := 0; n < 200; n++ {
+		os.Setenv("TERM", "term"+strconv.Itoa(n))
+		Refresh()
+	}
+	close(done)
+	wg.Wait()
+	if Terminal() != "term199" {
+		t.Errorf("Terminal() = %q after the last Refresh", Terminal())
+	}
+}
+
+type (
+	tFoo  string
+	tBar  int
+	tBaz  uint8
+	tFoos []tFoo
+)
+
+func TestNamedTypes(t *testing.T) {
+	var c struct {
+		Foo   tFoo
+		Bar   tBar
+		Baz   tBaz
+		Ptr   *tFoo
+		Foos  []tFoo
+		Bars  []tBar
+		Named tFoos `sep:","`
+		Dur   time.Duration
+	}
+	src := map[string]string{"FOO": "debug", "BAR": "-12", "BAZ": "255", "PTR": "info", "FOOS": "a:b", "BARS": "1:2", "NAMED": "x, y", "DUR": "2s"}
+	if err := ReadEnvVarsFromErr(src, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Foo != "debug" || c.Bar != -12 || c.Baz != 255 || c.Ptr == nil || *c.Ptr != "info" || c.Dur != 2*time.Second {
+		t.Errorf("read %+v", c)
+	}
+	if len(c.Foos) != 2 || c.Foos[1] != "b" || len(c.Bars) != 2 || c.Bars[1] != 2 || len(c.Named) != 2 || c.Named[1] != "y" {
+		t.Errorf("read slices %q %v %q", c.Foos, c.Bars, c.Named)
+	}
+}
+
+func TestIntLiterals(t *testing.T) {
+	tests := []struct {
+		envVal string
+		want   int64
+		bad    bool
+	}{
+		{envVal: "42", want: 42},
+		{envVal: "-42", want: -42},
+		{envVal: "+7", want: 7},
+		{envVal: "0xff", want: 255},
+		{envVal: "0XFF", want: 255},
+		{envVal: "-0x10", want: -16},
+		{envVal: "0o644", want: 0644},
+		{envVal: "0644", want: 0644},
+		{envVal: "010", want: 8}, // a leading 0 is octal, as in Go:  not 10 as before base 0
+		{envVal: "0b101", want: 5},
+		{envVal: "-0b11", want: -3},
+		{envVal: "1_000", want: 1000},
+		{envVal: "0080", bad: true}, // octal has no 8, give such a field `base:"10"`
+		{envVal: "0xg", bad: true},
+		{envVal: "12abc", bad: true},
+	}
+	for _, tc := range tests {
+		var c struct{ N int64 }
+		err := ReadEnvVarsFromErr(map[string]string{"N": tc.envVal}, &c)
+		switch {
+		case tc.bad && err == nil:
+			t.Errorf("N=%s read as %d, want an error", tc.envVal, c.N)
+		case !tc.bad && (err != nil || c.N != tc.want):
+			t.Errorf("N=%s read as %d (%v), want %d", tc.envVal, c.N, err, tc.want)
+		}
+	}
+
+	var c struct {
+		Port  int    `base:"10"`
+		Flags uint16 `base:"16"`
+		Mask  []int
+	}
+	if err := ReadEnvVarsFromErr(map[string]string{"PORT": "0080", "FLAGS": "ff", "MASK": "0x1:-0b10:010"}, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 80 || c.Flags != 0xff || len(c.Mask) != 3 || c.Mask[0] != 1 || c.Mask[1] != -2 || c.Mask[2] != 8 {
+		t.Errorf("read %+v", c)
+	}
+	if err := ReadEnvVarsFromErr(map[string]string{"PORT": "0x50"}, &c); err == nil {
+		t.Errorf("PORT=0x50 with base:\"10\" read as %d, want an error", c.Port)
+	}
+}
+
+func TestSepTidy(t *testing.T) {
+	tests := []struct {
+		envVal    string
+		tidy, raw []string
+	}{
+		{"A,B,C", []string{"A", "B", "C"}, []string{"A", "B", "C"}},
+		{"A, B , C", []string{"A", "B", "C"}, []string{"A", " B ", " C"}},
+		{",A,B,", []string{"A", "B"}, []string{"", "A", "B", ""}},
+		{" , A,,, B ,", []string{"A", "B"}, []string{" ", " A", "", "", " B ", ""}},
+		{"\tA\t,  B", []string{"A", "B"}, []string{"\tA\t", "  B"}},
+		{", ,", nil, []string{"", " ", ""}},
+	}
+	for _, tc := range tests {
+		src := map[string]string{"TAGS": tc.envVal}
+		var tidy struct {
+			Tags []string `sep:","`
+		}
+		var raw struct {
+			Tags []string `env:"TAGS,raw" sep:","`
+		}
+		if err := ReadEnvVarsFromErr(src, &tidy); err != nil {
+			t.Fatal(err)
+		}
+		if err := ReadEnvVarsFromErr(src, &raw); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(tidy.Tags, tc.tidy) {
+			t.Errorf("TAGS=%q read as %q, want %q", tc.envVal, tidy.Tags, tc.tidy)
+		}
+		if !reflect.DeepEqual(raw.Tags, tc.raw) {
+			t.Errorf("TAGS=%q read raw as %q, want %q", tc.envVal, raw.Tags, tc.raw)
+		}
+	}
+
+	// split on envSep (no sep tag) the elements are kept as they are
+	var c struct{ List []string }
+	if err := ReadEnvVarsFromErr(map[string]string{"LIST": " a" + envSep + envSep + "b "}, &c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.List, []string{" a", "", "b "}) {
+		t.Errorf("LIST read as %q", c.List)
+	}
+}
+
+type (
+	tCommon struct {
+		Host string `default:"localhost"`
+		Port int    `env:"PORT" default:"80"`
+		Name string
+	}
+	tMid struct {
+		tCommon
+		Port  int    `env:"PORT"` // shadows tCommon's
+		Level string `env:"LOG_LEVEL"`
+	}
+	tTop struct {
+		tMid
+		Name  string // shadows tCommon's, two levels down
+		Extra string
+	}
+	tExtra struct {
+		Region string
+		Zone   string `default:"a"`
+	}
+	tWithPtr struct {
+		*tExtra // unexported, never allocated
+		*TExtra
+		Name string
+	}
+	TExtra struct {
+		Cluster string
+	}
+)
+
+func TestEmbedded(t *testing.T) {
+	var c tTop
+	src := map[string]string{"PORT": "8080", "NAME": "top", "LOG_LEVEL": "debug", "EXTRA": "x"}
+	if err := ReadEnvVarsFromErr(src, &c); err != nil {
+		t.Fatal(err)
+	}
+	want := tTop{tMid: tMid{tCommon: tCommon{Host: "localhost"}, Port: 8080, Level: "debug"}, Name: "top", Extra: "x"}
+	if c != want {
+		t.Errorf("read %+v, want %+v", c, want)
+	}
+	if c.tCommon.Port != 0 || c.tCommon.Name != "" {
+		t.Errorf("shadowed fields were read: %+v", c.tCommon)
+	}
+
+	// embedded pointers:  only allocated when read into, kept if already set
+	var p tWithPtr
+	if err := ReadEnvVarsFromErr(map[string]string{"NAME": "n"}, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.TExtra != nil || p.tExtra != nil {
+		t.Errorf("nil embedded pointers allocated: %+v", p)
+	}
+	if err := ReadEnvVarsFromErr(map[string]string{"CLUSTER": "c1"}, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.TExtra == nil || p.Cluster != "c1" {
+		t.Errorf("CLUSTER not read into the embedded pointer: %+v", p)
+	}
+	p.tExtra = &tExtra{}
+	if err := ReadEnvVarsFromErr(map[string]string{"REGION": "eu"}, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Region != "eu" || p.Zone != "a" {
+		t.Errorf("REGION not read into the set, unexported embedded pointer: %+v", *p.tExtra)
+	}
+	if environ := ToEnviron(&tWithPtr{Name: "n"}); len(environ) != 1 || environ[0] != "NAME=n" {
+		t.Errorf("nil embedded pointers written: %q", environ)
+	}
+
+	// defaults' embedded pointers are copied, not read into
+	defs := tWithPtr{TExtra: &TExtra{Cluster: "def"}}
+	var got tWithPtr
+	t.Setenv("CLUSTER", "env")
+	if err := ReadEnvVarsWithDefaults(&defs, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Cluster != "env" || defs.Cluster != "def" {
+		t.Errorf("read %q over defaults, which became %q", got.Cluster, defs.Cluster)
+	}
+}
+
+func TestBlankValues(t *testing.T) {
+	type cfg struct {
+		Port    int           `default:"80"`
+		Debug   bool          `default:"true"`
+		Ratio   float64       `default:"0.5"`
+		Timeout time.Duration `default:"5s"`
+		Tags    []string      `default:"a:b"`
+		Name    *string
+		Key     string `env:"KEY,required"`
+	}
+	for _, blank := range []string{"", " ", "\t", " \n "} {
+		src := map[string]string{"PORT": blank, "DEBUG": blank, "RATIO": blank, "TIMEOUT": blank, "TAGS": blank, "NAME": blank, "KEY": blank}
+		var c cfg
+		err := ReadEnvVarsFromErr(src, &c)
+		if err == nil || !strings.Contains(err.Error(), "missing required env vars: KEY") {
+			t.Errorf("KEY=%q: %v, want KEY missing", blank, err)
+		}
+		if c.Port != 80 || !c.Debug || c.Ratio != 0.5 || c.Timeout != 5*time.Second || len(c.Tags) != 2 || c.Name != nil {
+			t.Errorf("%q values read as %+v, want the defaults", blank, c)
+		}
+	}
+
+	src := map[string]string{"PORT": "8080", "DEBUG": "no", "RATIO": "2", "TIMEOUT": "1m", "TAGS": "x", "NAME": "n", "KEY": "k"}
+	var c cfg
+	if err := ReadEnvVarsFromErr(src, &c); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 8080 || c.Debug || c.Ratio != 2 || c.Timeout != time.Minute || len(c.Tags) != 1 || c.Name == nil || *c.Name != "n" || c.Key != "k" {
+		t.Errorf("read %+v", c)
+	}
+
+	// padded values aren't blank, and aren't trimmed without the tag
+	if err := ReadEnvVarsFromErr(map[string]string{"PORT": " 8080 ", "KEY": "k"}, &c); err == nil {
+		t.Errorf("PORT=\" 8080 \" read as %d, want an error", c.Port)
+	}
+	var trimmed struct {
+		Port int `trim:"true"`
+	}
+	if err := ReadEnvVarsFromErr(map[string]string{"PORT": " 8080 "}, &trimmed); err != nil || trimmed.Port != 8080 {
+		t.Errorf("PORT=\" 8080 \" with trim read as %d, %v", trimmed.Port, err)
+	}
+}
+
+func TestWindowsShell(t *testing.T) {
+	t.Cleanup(func() { Reset("") })
+	const machine = `C:\Program Files\WindowsPowerShell\Modules;C:\WINDOWS\system32\WindowsPowerShell\v1.0\Modules`
+	t.Setenv("HOME", "")
+	t.Setenv("USERPROFILE", `C:\Users\bob`)
+	t.Setenv("ComSpec", `C:\WINDOWS\system32\cmd.exe`)
+	tests := []struct {
+		psModulePath, want string
+	}{
+		{"", "cmd"},
+		{machine, "cmd"}, // set machine wide, so in cmd too
+		{`C:\Users\bob\Documents\WindowsPowerShell\Modules;` + machine, "powershell"},
+		{`c:\users\BOB\OneDrive\Documents\PowerShell\Modules;C:\Program Files\PowerShell\Modules;` + machine, "powershell"},
+		{`C:\Users\bobby\Documents\PowerShell\Modules;` + machine, "cmd"}, // someone else's
+	}
+	for _, tc := range tests {
+		t.Setenv("PSModulePath", tc.psModulePath)
+		Reset("windows")
+		if Shell() != tc.want {
+			t.Errorf("PSModulePath=%q: Shell() = %q, want %q", tc.psModulePath, Shell(), tc.want)
+		}
+	}
+}
+
+func TestDuplicateNames(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  interface{}
+		dup  string // "" if no error
+	}{
+		{"derived", &struct{ MaxConns, Maxconns int }{}, "MAXCONNS"},
+		{"tagged", &struct {
+			A string `env:"X"`
+			B string `env:"X"`
+		}{}, "X"},
+		{"alternate", &struct {
+			A string `env:"X,Y"`
+			B string `env:"Y"`
+		}{}, "Y"},
+		{"alternates", &struct {
+			A string `env:"X,Y"`
+			B string `env:"Z,Y"`
+		}{}, "Y"},
+		{"nested apart", &struct {
+			Host string
+			DB   struct{ Host string }
+		}{}, ""},
+		{"own alternate", &struct {
+			A string `env:"X,X"`
+		}{}, ""},
+	}
+	for _, tc := range tests {
+		err := ReadEnvVarsFromErr(map[string]string{}, tc.cfg)
+		switch {
+		case tc.dup == "" && err != nil:
+			t.Errorf("%s: %v", tc.name, err)
+		case tc.dup != "" && (err == nil || !strings.HasSuffix(err.Error(), "both read "+tc.dup)):
+			t.Errorf("%s: %v, want both reading %s", tc.name, err, tc.dup)
+		}
+	}
+	var c struct{ MaxConns, Max_Conns int }
+	if err := ReadEnvVarsOpts(&c, FromMap(map[string]string{}), AnyCase()); err == nil {
+		t.Errorf("AnyCase: MaxConns & Max_Conns both read MAX_CONNS, no error")
+	}
+}
+
+func TestNotStructPointer(t *testing.T) {
+	var c struct{ Port int }
+	var nilPtr *struct{ Port int }
+	port := 0
+	for _, i := range []interface{}{c, &port, nilPtr, nil} {
+		if err := ReadEnvVarsErr(i); err == nil {
+			t.Errorf("ReadEnvVarsErr(%T) read, want an error", i)
+		}
+	}
+}
+
+func TestRequiredGathered(t *testing.T) {
+	type server struct{ Host string }
+	var c struct {
+		Labels  map[string]string `env:"LBL,required"`
+		Servers []server          `required:"true"`
+		Extra   map[string]string // not required
+	}
+	err := ReadEnvVarsFromErr(map[string]string{}, &c)
+	if err == nil || !strings.Contains(err.Error(), "LBL_* (field Labels)") || !strings.Contains(err.Error(), "SERVERS_0_* (field Servers)") {
+		t.Errorf("no env vars: %v, want Labels & Servers missing", err)
+	}
+	if strings.Contains(fmt.Sprint(err), "EXTRA") {
+		t.Errorf("Extra isn't required: %v", err)
+	}
+	if err := ReadEnvVarsFromErr(map[string]string{"LBL_env": "prod", "SERVERS_0_HOST": "a"}, &c); err != nil {
+		t.Errorf("with env vars: %v", err)
+	}
+}
+
+func TestOnMissing(t *testing.T) {
+	t.Cleanup(func() { OnMissing = nil })
+	missed := []string{}
+	OnMissing = func(field, envName string) { missed = append(missed, field+"="+envName) }
+	Refresh() // the package's own aren't reported
+	var c struct {
+		Port int    `env:"OM_PORT" default:"80"`
+		Name string `env:"OM_NAME"`
+	}
+	if err := ReadEnvVarsFromErr(map[string]string{"OM_NAME": "n"}, &c); err != nil {
+		t.Fatal(err)
+	}
+	if len(missed) != 1 || missed[0] != "Port=OM_PORT" {
+		t.Errorf("OnMissing got %q, want only Port=OM_PORT", missed)
+	}
+}
+
+func TestHostEnvVar(t *testing.T) {
+	t.Cleanup(func() { Reset("") })
+	t.Setenv("HOST", "buildbox") // as tcsh exports it
+	t.Setenv("KUBERNETES_SERVICE_HOST", "10.0.0.1")
+	Reset("linux")
+	if Host() != "buildbox" || ContainerRuntime() != "kubernetes" {
+		t.Errorf("HOST=buildbox: Host() = %q, ContainerRuntime() = %q", Host(), ContainerRuntime())
+	}
+
+	t.Setenv("USERNAME", `CORP\bob`)
+	t.Setenv("USER", "")
+	t.Setenv("ComSpec", `C:\WINDOWS\system32\cmd.exe`)
+	t.Setenv("PSModulePath", "")
+	Reset("windows")
+	if User() != "bob" || Shell() != "cmd" {
+		t.Errorf("HOST=buildbox on windows: User() = %q, Shell() = %q", User(), Shell())
+	}
+}
+
+func TestEmptyElements(t *testing.T) {
+	var c struct {
+		A   [3]int
+		I   []int
+		F   []float64
+		D   []time.Duration
+		Tag []int `env:"TAG,nonempty"`
+	}
+	for _, name := range []string{"A", "I", "F", "D"} {
+		if err := ReadEnvVarsFromErr(map[string]string{name: "1::3"}, &c); err == nil {
+			t.Errorf("%s=1::3 read, want an error", name)
+		}
+	}
+	if err := ReadEnvVarsFromErr(map[string]string{"A": "1:2:3", "TAG": "1::3"}, &c); err != nil || c.A != [3]int{1, 2, 3} || len(c.Tag) != 2 {
+		t.Errorf("read %+v, %v", c, err)
+	}
+}
+
+func TestNotEmptyGathered(t *testing.T) {
+	type server struct{ Host string }
+	var c struct {
+		Labels  map[string]string `prefix:"LBL_" notEmpty:"true"`
+		Servers []server          `notEmpty:"true"`
+	}
+	err := ReadEnvVarsFromErr(map[string]string{"SERVERS_0_HOST": "a"}, &c)
+	if err == nil || !strings.Contains(err.Error(), "field Labels") {
+		t.Errorf("no LBL_ vars: %v, want Labels empty", err)
+	}
+	err = ReadEnvVarsFromErr(map[string]string{"LBL_a": "1"}, &c)
+	if err == nil || !strings.Contains(err.Error(), "field Servers") {
+		t.Errorf("no SERVERS_ vars: %v, want Servers empty", err)
+	}
+	if err := ReadEnvVarsFromErr(map[string]string{"LBL_a": "1", "SERVERS_0_HOST": "a"}, &c); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBlankGathered(t *testing.T) {
+	var c struct {
+		Labels map[string]string `env:"LBL,required"`
+	}
+	err := ReadEnvVarsFromErr(map[string]string{"LBL_": "x", "LBL_a": " ", "LBL_b": ""}, &c)
+	if err == nil || !strings.Contains(err.Error(), "LBL_*") {
+		t.Errorf("only blank & bare LBL_ vars: %v, want LBL missing", err)
+	}
+	if c.Labels != nil {
+		t.Errorf("gathered %q", c.Labels)
+	}
+	if err := ReadEnvVarsFromErr(map[string]string{"LBL_a": " ", "LBL_c": "3"}, &c); err != nil || len(c.Labels) != 1 || c.Labels["c"] != "3" {
+		t.Errorf("gathered %q, %v", c.Labels, err)
+	}
+}
@@ -0,0 +1,105 @@
+package env
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/* ========================================================================= //
+	The error returning readers give a *ParseError when a value can't be
+	converted for its field, so callers can see which field & env var it was,
+	and get at the strconv (or time...) error under it with errors.Is / As.
+// ------------------------------------------------------------------------- */
+
+// ParseError -- a value that couldn't be converted for its field
+type ParseError struct {
+	Field   string       // Go field path: Database.Port
+	EnvName string       // env var read
+	Value   string       // its value
+	Kind    reflect.Kind // the field's kind
+	Err     error        // the conversion's error
+
+	what string // what the value was converted as, if more telling than Kind
+}
+
+func (e *ParseError) Error() string {
+	what := e.what
+	if what == "" {
+		what = e.Kind.String()
+	}
+	msg := fmt.Sprintf("Illegal %s conversion %s=%q: %v", what, e.EnvName, e.Value, e.Err)
+	if e.Field != "" {
+		msg = fmt.Sprintf("ReadEnvVars: field %s: %s", e.Field, msg)
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// read the env vars into the structure as ReadEnvVarsErr, on an error print it to stderr and exit(1),
+// for CLI tools that want a message rather than a panic's stack
+func MustRead(i interface{}) {
+	err := ReadEnvVarsErr(i)
+	if err == nil {
+		return
+	}
+	if pe, ok := err.(*ParseError); ok {
+		fmt.Fprintf(os.Stderr, "ReadEnvVars: %s %s=%s: %v\n", pe.Field, pe.EnvName, pe.Value, pe.Err)
+	} else {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	os.Exit(1)
+}
+
+// fieldErr -- name the field in a reader's error: filled into a ParseError, else prefixed
+func fieldErr(path string, err error) error {
+	if pe, ok := err.(*ParseError); ok {
+		pe.Field = path
+		return pe
+	}
+	return fmt.Errorf("ReadEnvVars: field %s: %v", path, err)
+}
+
+// redact -- hide a secret field's value in its error, a ParseError keeps its fields but not its Err's type;
+// every form the value may be in the message is masked, see secretForms
+func redact(err error, envVal string) error {
+	forms := secretForms(envVal)
+	pe, ok := err.(*ParseError)
+	if ok {
+		forms = append(forms, secretForms(pe.Value)...) // a slice element's, or as trimmed
+	}
+	sort.Slice(forms, func(a, b int) bool { return len(forms[a]) > len(forms[b]) }) // "a b" before "a"
+	mask := func(msg string) string {
+		for _, form := range forms {
+			msg = strings.ReplaceAll(msg, form, SecretMask)
+		}
+		return msg
+	}
+	if ok {
+		pe.Err = errors.New(mask(pe.Err.Error()))
+		pe.Value = SecretMask
+		return pe
+	}
+	return errors.New(mask(err.Error()))
+}
+
+// secretForms -- the value as errors may hold it:  raw, trimmed & unquoted (as trim tags do), and each
+// of those as quoted by %q / strconv.Quote (less its quotes), hunter"2 as hunter\"2
+func secretForms(envVal string) []string {
+	forms := []string{}
+	for _, v := range []string{envVal, strings.TrimSpace(envVal), (fieldTags{unquote: true}).clean(envVal)} {
+		if v == "" {
+			continue
+		}
+		quoted := strconv.Quote(v)
+		forms = append(forms, v, quoted[1:len(quoted)-1])
+	}
+	return forms
+}
@@ -0,0 +1,50 @@
+package env
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSecretErrors(t *testing.T) {
+	tests := []struct {
+		name, secret string
+		cfg          interface{}
+		src          map[string]string
+	}{
+		{"parse", "hunter2", &struct {
+			Pin int `env:"PIN,secret"`
+		}{}, map[string]string{"PIN": "hunter2"}},
+		{"quoted by strconv", `hunter"2`, &struct {
+			Pin int `env:"PIN,secret"`
+		}{}, map[string]string{"PIN": `hunter"2`}},
+		{"trimmed", "topsecret", &struct {
+			Level string `env:"LVL,secret" trim:"true" oneof:"a b"`
+		}{}, map[string]string{"LVL": " topsecret "}},
+		{"unquoted", "topsecret", &struct {
+			Level string `env:"LVL,secret" trim:"quotes" oneof:"a b"`
+		}{}, map[string]string{"LVL": ` "topsecret" `}},
+		{"trimmed & quoted", `top"secret`, &struct {
+			Pin int `env:"PIN,secret" trim:"true"`
+		}{}, map[string]string{"PIN": ` top"secret `}},
+		{"element", "s3cr3t", &struct {
+			Pins []int `env:"PINS,secret"`
+		}{}, map[string]string{"PINS": "1:s3cr3t"}},
+		{"range", "99999", &struct {
+			Pin uint8 `env:"PIN,secret"`
+		}{}, map[string]string{"PIN": "99999"}},
+	}
+	for _, tc := range tests {
+		err := ReadEnvVarsFromErr(tc.src, tc.cfg)
+		if err == nil {
+			t.Errorf("%s: no error", tc.name)
+			continue
+		}
+		msg := err.Error()
+		if strings.Contains(msg, strings.TrimSpace(tc.secret)) || strings.Contains(msg, strings.ReplaceAll(tc.secret, `"`, `\"`)) {
+			t.Errorf("%s: secret in %q", tc.name, msg)
+		}
+		if !strings.Contains(msg, SecretMask) {
+			t.Errorf("%s: %q, want %s in it", tc.name, msg, SecretMask)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+/* ========================================================================= //
+	For reading a single env var without building a structure.  The values
+	are converted by the same rules as ReadEnvVars uses for its fields.
+
+	The Lookup functions report if the env var is set at all (it may be set
+	but empty), as os.LookupEnv does.
+
+	Get & GetOr take the type to convert to:  port, err := env.Get[int]("PORT")
+
+	GetInt, GetBool, GetDuration & GetString are GetOr for the everyday types:
+		timeout := env.GetDuration("TIMEOUT", 30*time.Second)
+
+	AllEnv is the whole environment as a map, AllEnvPrefix only the env vars
+	whose names start with the prefix.
+// ------------------------------------------------------------------------- */
+
+// return the env var's value and if it is set
+func Lookup(name string) (string, bool) {
+	return os.LookupEnv(name)
+}
+
+// return the env var as an int, if it is set, and any conversion error
+func LookupInt(name string) (int, bool, error) {
+	var v int
+	set, err := lookupAs(name, &v)
+	return v, set, err
+}
+
+// return the env var as a bool, if it is set, and any conversion error
+func LookupBool(name string) (bool, bool, error) {
+	var v bool
+	set, err := lookupAs(name, &v)
+	return v, set, err
+}
+
+// lookupAs -- convert any env var into what p points to, returns if the env var is set
+func lookupAs(name string, p interface{}) (bool, error) {
+	envVal, set := os.LookupEnv(name)
+	if !set {
+		return false, nil
+	}
+	if err := setEnvVal(fieldTags{name: name}, envVal, reflect.ValueOf(p).Elem()); err != nil {
+		return true, fmt.Errorf("Lookup: %w", err)
+	}
+	return true, nil
+}
+
+// return the env var converted to T, the zero value if it isn't set
+func Get[T any](name string) (T, error) {
+	var v T
+	_, err := lookupAs(name, &v)
+	return v, err
+}
+
+// return the env var converted to T, def if it isn't set, is blank or can't be converted
+func GetOr[T any](name string, def T) T {
+	var v T
+	if blank(os.Getenv(name)) {
+		return def
+	}
+	if _, err := lookupAs(name, &v); err != nil {
+		return def
+	}
+	return v
+}
+
+// return the env var as an int, def if it isn't set, is empty or isn't an int
+func GetInt(name string, def int) int {
+	return GetOr(name, def)
+}
+
+// return the env var as a bool (1/0, true/false, yes/no, on/off), def if it isn't set, is empty or isn't a bool
+func GetBool(name string, def bool) bool {
+	return GetOr(name, def)
+}
+
+// return the env var as a time.Duration (30s, 1h15m...), def if it isn't set, is empty or isn't a duration
+func GetDuration(name string, def time.Duration) time.Duration {
+	return GetOr(name, def)
+}
+
+// return the env var, def if it isn't set or is empty
+func GetString(name, def string) string {
+	return GetOr(name, def)
+}
+
+// return all the env vars, by name
+func AllEnv() map[string]string {
+	return environMap()
+}
+
+// return the env vars whose names start with prefix, keyed by the rest of the name if strip
+func AllEnvPrefix(prefix string, strip bool) map[string]string {
+	vars := map[string]string{}
+	for name, envVal := range environMap() {
+		if strings.HasPrefix(name, prefix) {
+			if strip {
+				name = name[len(prefix):]
+			}
+			vars[name] = envVal
+		}
+	}
+	return vars
+}
@@ -0,0 +1,3 @@
+module github.com/jayacarlson/env
+
+go 1.21
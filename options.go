@@ -0,0 +1,330 @@
+package env
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+/* ========================================================================= //
+	ReadEnvVarsOpts takes any Options changing how the env vars are found:
+
+		err := env.ReadEnvVarsOpts(&myEnvVars, env.WithPrefix("APP1"), env.AnyCase())
+
+	SnakeCase derives MAX_CONNS for a field MaxConns instead of MAXCONNS
+	(runs of capitals are one word, HTTPPort is HTTP_PORT, as is one with
+	a version:  IPv6Addr is IPV6_ADDR), tags still win.
+
+	StrictRead reads with a prefix, then errors on any env var with that prefix
+	no field read (an empty prefix would check the whole environment).
+
+	LenientRead is the best effort read:  a field whose env var doesn't convert
+	is left as if the env var was unset (zero, or its `default`) and the read
+	carries on, returning all the errors for logging.  ReadEnvVarsErr stops at
+	the first error, MustRead exits on it.
+
+	FileSecrets reads an unset DB_PASSWORD from the file DB_PASSWORD_FILE names.
+
+	ReadEnvVarsTracked also says which fields were set from the environment,
+	for merging with other config:  defaults < file < env.
+
+	SetGlobalPrefix gives every read the prefix WithPrefix would, for a binary
+	whose env vars all share one:  it is process wide, set it once at start.
+
+	AnyCase is for env vars not in the usual upper case: a field MaxConns
+	(not tagged) reads the first set of:  MaxConns, MAXCONNS, MAX_CONNS
+// ------------------------------------------------------------------------- */
+
+// Option -- changes how ReadEnvVarsOpts finds the env vars
+type Option func(*options)
+
+// options -- how a read is done
+type options struct {
+	lookup    lookupFn                 // where values come from
+	vars      func() map[string]string // all of them, for map fields
+	prefix    string                   // prepended to every name
+	anyCase   bool                     // try the field name as is, upper & snake cased
+	snake     bool                     // derive names in upper snake case
+	used      map[string]bool          // if set, the names found are added
+	keep      bool                     // default tags only fill fields still zero
+	files     bool                     // read <NAME>_FILE's file for an unset NAME
+	lenient   bool                     // field errors are added to errs, not returned
+	errs      []error
+	tracked   map[string]bool             // if set, each field's path is added, true if its env var had a value
+	skipNil   bool                        // nil embedded *Struct fields aren't walked, for the writers & Dump
+	copies    bool                        // set embedded *Struct fields are read into copies, the target's are shared
+	onMissing func(field, envName string) // OnMissing as the read started, nil for the package's own
+}
+
+// read the values from src instead of the environment
+func FromMap(src map[string]string) Option {
+	return func(o *options) {
+		o.lookup = mapLookup(src)
+		o.vars = func() map[string]string { return src }
+	}
+}
+
+// prefix every env var name (derived or tagged): "APP1" reads APP1_HOST for Host
+func WithPrefix(prefix string) Option {
+	return func(o *options) {
+		o.prefix = underscored(prefix)
+	}
+}
+
+var (
+	prefixMu     sync.RWMutex
+	globalPrefix string // SetGlobalPrefix's, with its '_'
+)
+
+// set a prefix every reader (and WriteEnvVars, Snapshot, Dump) uses from now on, process wide:  "MYAPP" reads
+// MYAPP_HOST for Host;  one given a prefix (ReadEnvVarsPrefix, WithPrefix, StrictRead) uses that, "" clears it
+func SetGlobalPrefix(prefix string) {
+	prefixMu.Lock()
+	defer prefixMu.Unlock()
+	globalPrefix = underscored(prefix)
+}
+
+// underscored -- the prefix with a trailing '_', if any prefix
+func underscored(prefix string) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return prefix
+}
+
+// for untagged fields use the first env var set of the field name as is, upper cased, or upper snake cased
+func AnyCase() Option {
+	return func(o *options) {
+		o.anyCase = true
+	}
+}
+
+// derive untagged names in upper snake case: MaxConns reads MAX_CONNS, HTTPPort reads HTTP_PORT
+func SnakeCase() Option {
+	return func(o *options) {
+		o.snake = true
+	}
+}
+
+// for a field whose env var isn't set, read the value from the file named by <NAME>_FILE, as
+// docker & kubernetes secrets give them:  DB_PASSWORD_FILE=/run/secrets/db (one trailing newline is dropped)
+func FileSecrets() Option {
+	return func(o *options) {
+		o.files = true
+	}
+}
+
+// as ReadEnvVarsErr, but changed by any options
+func ReadEnvVarsOpts(i interface{}, opts ...Option) error {
+	return readEnvVars(newOptions(opts), i)
+}
+
+// as ReadEnvVarsPrefixErr, then errors listing any env vars starting with the prefix that no field read,
+// catching typos like APP_HTTP_TIMOUT
+func StrictRead(prefix string, i interface{}) error {
+	o := newOptions([]Option{WithPrefix(prefix)})
+	o.used = map[string]bool{}
+	if err := readEnvVars(o, i); err != nil {
+		return err
+	}
+	unknown := []string{}
+	for name := range o.vars() {
+		if strings.HasPrefix(name, o.prefix) && !o.used[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("StrictRead: unknown env vars: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// as ReadEnvVarsErr, but every field is tried:  one that fails to convert is left as if its env var
+// was unset, with the errors (those and any missing required or Validate error) returned, nil if none
+func LenientRead(i interface{}) []error {
+	o := newOptions(nil)
+	o.lenient = true
+	if err := readEnvVars(o, i); err != nil {
+		o.errs = append(o.errs, err)
+	}
+	return o.errs
+}
+
+// as ReadEnvVarsErr, also returning which fields' env vars had a value, by Go field path (Port,
+// Database.Host, Servers[0].Host...), so values from the environment can be told from defaults
+func ReadEnvVarsTracked(i interface{}) (set map[string]bool, err error) {
+	o := newOptions(nil)
+	o.tracked = map[string]bool{}
+	err = readEnvVars(o, i)
+	return o.tracked, err
+}
+
+// as ReadEnvVarsErr, but target (a pointer to a struct) is first set from defaults (the same struct type,
+// or a pointer to it):  fields whose env var is unset keep the default's value, with `default` tags only
+// used for fields still zero
+func ReadEnvVarsWithDefaults(defaults, target interface{}) error {
+	t := reflect.ValueOf(target)
+	d := reflect.Indirect(reflect.ValueOf(defaults))
+	if !d.IsValid() || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct || t.Elem().Type() != d.Type() {
+		return fmt.Errorf("ReadEnvVarsWithDefaults: defaults %T doesn't match target %T", defaults, target)
+	}
+	t.Elem().Set(d)
+	o := newOptions(nil)
+	o.keep, o.copies = true, true
+	return readEnvVars(o, target)
+}
+
+// newOptions -- the defaults, changed by opts
+func newOptions(opts []Option) *options {
+	prefixMu.RLock()
+	o := &options{lookup: os.LookupEnv, vars: environMap, prefix: globalPrefix, onMissing: OnMissing}
+	prefixMu.RUnlock()
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// find -- look up the field's env var value, trying its names left to right (see names) and using
+// the first with a value (not blank), then any <NAME>_FILE if FileSecrets; tags.name is set to the name used
+func (o *options) find(tags *fieldTags) (string, error) {
+	envVal, found := "", ""
+	names := o.names(tags)
+	for _, name := range names {
+		if v, set := o.lookup(name); set {
+			o.use(name)
+			if found == "" && !blank(v) {
+				envVal, found = v, name
+			}
+		}
+	}
+	if found == "" && o.files {
+		for _, name := range names {
+			if path, set := o.lookup(name + "_FILE"); set && path != "" {
+				o.use(name + "_FILE")
+				b, err := os.ReadFile(path)
+				if err != nil {
+					return "", fmt.Errorf("Reading %s_FILE: %v", name, err)
+				}
+				envVal, found = strings.TrimSuffix(strings.TrimSuffix(string(b), "\n"), "\r"), name+"_FILE"
+				break
+			}
+		}
+	}
+	if found == "" || blank(envVal) {
+		return "", nil
+	}
+	tags.name = found
+	return envVal, nil
+}
+
+// present -- if any of the field's names is set, to any value
+func (o *options) present(tags *fieldTags) bool {
+	set := false
+	for _, name := range o.names(tags) {
+		if _, ok := o.lookup(name); ok {
+			o.use(name)
+			set = true
+		}
+	}
+	return set
+}
+
+// maxExpand -- how many times expand goes over a value, for references to references
+const maxExpand = 8
+
+// expand -- replace the ${VAR} & $VAR references in name's value with their values (as looked up), erroring
+// if they still change after maxExpand passes:  a loop (A=$B B=$A), or references nested too deep
+func (o *options) expand(name, envVal string) (string, error) {
+	for n := 0; strings.Contains(envVal, "$"); n++ {
+		next := os.Expand(envVal, func(name string) string {
+			v, _ := o.lookup(name)
+			return v
+		})
+		if next == envVal {
+			break
+		}
+		if n == maxExpand {
+			return "", fmt.Errorf("Expanding %s: references still changing after %d passes, a loop?", name, maxExpand) // the value may be secret
+		}
+		envVal = next
+	}
+	return envVal, nil
+}
+
+// names -- the env var names a field may be read from: its name (as is, upper & snake cased for
+// AnyCase if not tagged), then any alternates from the tag `env:"NAME,OLD_NAME"`
+func (o *options) names(tags *fieldTags) []string {
+	names := []string{tags.name}
+	if !tags.tagged && o.anyCase {
+		names = []string{tags.prefix + tags.field, tags.name, tags.prefix + snakeCase(tags.field)}
+	}
+	for _, alt := range tags.alts {
+		names = append(names, tags.prefix+alt)
+	}
+	return names
+}
+
+// fail -- the field's error, or nil once noted if lenient
+func (o *options) fail(path string, err error) error {
+	err = fieldErr(path, err)
+	if !o.lenient {
+		return err
+	}
+	o.errs = append(o.errs, err)
+	return nil
+}
+
+// checkNotEmpty -- the field's error if tagged notEmpty and empty once read, see fail
+func (o *options) checkNotEmpty(tags fieldTags, path string, field reflect.Value) error {
+	if tags.notEmpty && emptyValue(field) {
+		return o.fail(path, fmt.Errorf("Empty value for %s, tagged notEmpty", tags.name))
+	}
+	return nil
+}
+
+// track -- note if the field was set from the environment, if anyone is asking
+func (o *options) track(path string, set bool) {
+	if o.tracked != nil {
+		o.tracked[path] = set
+	}
+}
+
+// use -- note the env var was read, if anyone is asking
+func (o *options) use(name string) {
+	if o.used != nil {
+		o.used[name] = true
+	}
+}
+
+// snakeCase -- upper case the name with '_' between words: MaxConns is MAX_CONNS, HTTPPort is HTTP_PORT;
+// a run of capitals ends before the last if lower case follows it, unless a digit follows that (IPv6Addr
+// is IPV6_ADDR, OAuth2Token OAUTH2_TOKEN)
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var sb strings.Builder
+	for n, r := range runes {
+		if n > 0 && unicode.IsUpper(r) {
+			prev := runes[n-1]
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && wordStart(runes[n+1:])) {
+				sb.WriteByte('_')
+			}
+		}
+		sb.WriteRune(unicode.ToUpper(r))
+	}
+	return sb.String()
+}
+
+// wordStart -- if what follows a capital makes it a word's first letter: lower case, not then a digit
+func wordStart(rest []rune) bool {
+	n := 0
+	for n < len(rest) && unicode.IsLower(rest[n]) {
+		n++
+	}
+	return n > 0 && !(n < len(rest) && unicode.IsDigit(rest[n]))
+}
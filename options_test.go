@@ -0,0 +1,95 @@
+package env
+
+import "testing"
+
+func TestWithDefaultsMismatch(t *testing.T) {
+	type cfg struct {
+		Port int `env:"WD_TEST_PORT"`
+	}
+	var c cfg
+	var nilCfg *cfg
+	tests := []struct {
+		name             string
+		defaults, target interface{}
+	}{
+		{"nil defaults", nil, &c},
+		{"nil pointer defaults", nilCfg, &c},
+		{"nil target", cfg{}, nil},
+		{"nil pointer target", cfg{}, nilCfg},
+		{"not a pointer", cfg{}, c},
+		{"other type", struct{ Port int }{}, &c},
+	}
+	for _, tc := range tests {
+		if err := ReadEnvVarsWithDefaults(tc.defaults, tc.target); err == nil {
+			t.Errorf("%s: no error", tc.name)
+		}
+	}
+	defs := cfg{Port: 8080}
+	if err := ReadEnvVarsWithDefaults(defs, &c); err != nil || c.Port != 8080 {
+		t.Errorf("read %+v, %v", c, err)
+	}
+
+	// a map from the defaults is copied, not gathered into
+	type labels struct {
+		Labels map[string]string `prefix:"WD_LABELS_"`
+	}
+	withMap := labels{Labels: map[string]string{"a": "1"}}
+	var got labels
+	t.Setenv("WD_LABELS_b", "2")
+	if err := ReadEnvVarsWithDefaults(&withMap, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Labels) != 2 || got.Labels["a"] != "1" || got.Labels["b"] != "2" {
+		t.Errorf("read %v over the defaults", got.Labels)
+	}
+	if len(withMap.Labels) != 1 {
+		t.Errorf("the defaults' map became %v", withMap.Labels)
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for name, want := range map[string]string{
+		"Port":        "PORT",
+		"MaxConns":    "MAX_CONNS",
+		"HTTPPort":    "HTTP_PORT",
+		"UserID":      "USER_ID",
+		"APIKey":      "API_KEY",
+		"IPv6Addr":    "IPV6_ADDR",
+		"IPv4":        "IPV4",
+		"OAuth2Token": "OAUTH2_TOKEN",
+		"Http2Server": "HTTP2_SERVER",
+		"S3Bucket":    "S3_BUCKET",
+		"ID":          "ID",
+		"X":           "X",
+	} {
+		if got := snakeCase(name); got != want {
+			t.Errorf("snakeCase(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	type cfg struct {
+		A string `env:"A" expand:"true"`
+	}
+	tests := []struct {
+		src  map[string]string
+		want string // "" for an error
+	}{
+		{map[string]string{"A": "${B}/logs", "B": "/var"}, "/var/logs"},
+		{map[string]string{"A": "$B", "B": "$C", "C": "deep"}, "deep"},
+		{map[string]string{"A": "cost $5", "B": "x"}, "cost "},
+		{map[string]string{"A": "$B", "B": "$A"}, ""},
+		{map[string]string{"A": "x$A"}, ""},
+	}
+	for _, tc := range tests {
+		var c cfg
+		err := ReadEnvVarsFromErr(tc.src, &c)
+		switch {
+		case tc.want == "" && err == nil:
+			t.Errorf("%v: A read as %q, want a loop error", tc.src, c.A)
+		case tc.want != "" && (err != nil || c.A != tc.want):
+			t.Errorf("%v: A read as %q (%v), want %q", tc.src, c.A, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package env
+
+import (
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+/* ========================================================================= //
+	RegisterParser extends what ReadEnvVars (and Get, Lookup...) can read:
+	a field of exactly the registered type is given to its parser instead of
+	the built-in conversions, which stay the defaults for everything else.
+
+		env.RegisterParser(reflect.TypeOf(uuid.UUID{}), func(s string) (interface{}, error) {
+			return uuid.Parse(s)
+		})
+
+	RegisterValueParser is the same for a parser making the reflect.Value
+	itself, as generic code building values by reflection has them:  both
+	register in the one registry, the last registration of a type wins.
+
+	Register from init functions, before any reads:  the registry is guarded
+	so it is safe either way, but a read racing a registration may or may
+	not see it.  A registered struct type is read whole, not as a nested one.
+
+	WriteEnvVars & ToEnviron write a registered type with its MarshalText or
+	String method.
+
+	RGB is one, registered here:  RGB colors written #RRGGBB or RRGGBB.
+// ------------------------------------------------------------------------- */
+
+// valueParser -- a registered parser, giving the field's new value
+type valueParser func(string) (reflect.Value, error)
+
+var (
+	parsersMu sync.RWMutex
+	parsers   = map[reflect.Type]valueParser{}
+)
+
+// have fields of type t read with parse, its result must be a t
+func RegisterParser(t reflect.Type, parse func(string) (interface{}, error)) {
+	RegisterValueParser(t, func(s string) (reflect.Value, error) {
+		v, err := parse(s)
+		return reflect.ValueOf(v), err
+	})
+}
+
+// have fields of type t read with parse, its result must be assignable to a t
+func RegisterValueParser(t reflect.Type, parse func(string) (reflect.Value, error)) {
+	parsersMu.Lock()
+	parsers[t] = valueParser(parse)
+	parsersMu.Unlock()
+	fieldPlans.Range(func(key, _ interface{}) bool {
+		fieldPlans.Delete(key) // any made may have walked t as a nested struct
+		return true
+	})
+}
+
+// parserFor -- the registered parser of type t, if any
+func parserFor(t reflect.Type) (valueParser, bool) {
+	parsersMu.RLock()
+	defer parsersMu.RUnlock()
+	parse, ok := parsers[t]
+	return parse, ok
+}
+
+// setParsed -- set the field with its registered parser
+func setParsed(parse valueParser, tags fieldTags, envVal string, field reflect.Value) error {
+	v, err := parse(envVal)
+	if err != nil {
+		return convErr(field.Type().String(), field, tags.name, envVal, err)
+	}
+	if !v.IsValid() {
+		return fmt.Errorf("Parser for %v gave nothing for %s", field.Type(), tags.name)
+	}
+	if !v.Type().AssignableTo(field.Type()) {
+		return fmt.Errorf("Parser for %v gave a %v for %s", field.Type(), v.Type(), tags.name)
+	}
+	field.Set(v)
+	return nil
+}
+
+// fmtParsed -- a registered type's value as its MarshalText or String makes it
+func fmtParsed(tags fieldTags, field reflect.Value) (string, bool, error) {
+	switch v := field.Interface().(type) {
+	case encoding.TextMarshaler:
+		b, err := v.MarshalText()
+		return string(b), err == nil, err
+	case fmt.Stringer:
+		return v.String(), true, nil
+	}
+	return "", false, fmt.Errorf("Unsupported type %v for %s, it has no MarshalText or String", field.Type(), tags.name)
+}
+
+// RGB -- a color, read from #RRGGBB or RRGGBB
+type RGB struct {
+	R, G, B uint8
+}
+
+func init() {
+	RegisterParser(reflect.TypeOf(RGB{}), func(s string) (interface{}, error) {
+		return ParseRGB(s)
+	})
+}
+
+// parse a #RRGGBB or RRGGBB color
+func ParseRGB(s string) (RGB, error) {
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "#"))
+	if err != nil || len(b) != 3 {
+		return RGB{}, fmt.Errorf("not a #RRGGBB color")
+	}
+	return RGB{b[0], b[1], b[2]}, nil
+}
+
+// the color as #rrggbb
+func (c RGB) String() string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,73 @@
+package env
+
+import (
+	"os"
+	"strings"
+)
+
+/* ========================================================================= //
+	Probing for the environment around the process, beyond its env vars:
+	done by getEnv with the rest, so the results are there before any init().
+// ------------------------------------------------------------------------- */
+
+// simple boolean if running under Windows Subsystem for Linux (Host() is still 'linux')
+func IsWSL() bool {
+	return current().wsl
+}
+
+// detectWSL -- WSL sets WSL_DISTRO_NAME / WSL_INTEROP, and its kernel's version names microsoft
+func detectWSL(system string) bool {
+	if system != "linux" {
+		return false
+	}
+	if os.Getenv("WSL_DISTRO_NAME") != "" || os.Getenv("WSL_INTEROP") != "" {
+		return true
+	}
+	version, err := os.ReadFile("/proc/version")
+	return err == nil && strings.Contains(strings.ToLower(string(version)), "microsoft")
+}
+
+// simple boolean if running in a container, see ContainerRuntime
+func InContainer() bool {
+	return current().container != ""
+}
+
+// return what runs the process's container: 'kubernetes' | 'podman' | 'docker', or "" when not in one
+func ContainerRuntime() string {
+	return current().container
+}
+
+// detectContainer -- go by the env vars & files the runtimes leave, then by the cgroup of pid 1
+func detectContainer(system string) string {
+	if system != "linux" {
+		return ""
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+	if os.Getenv("container") == "podman" || fileExists("/run/.containerenv") {
+		return "podman"
+	}
+	if fileExists("/.dockerenv") {
+		return "docker"
+	}
+	cgroup, err := os.ReadFile("/proc/1/cgroup")
+	if err != nil {
+		return ""
+	}
+	switch cg := string(cgroup); {
+	case strings.Contains(cg, "kubepods"):
+		return "kubernetes"
+	case strings.Contains(cg, "libpod"):
+		return "podman"
+	case strings.Contains(cg, "docker"):
+		return "docker"
+	}
+	return ""
+}
+
+// fileExists -- if anything is at the path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,71 @@
+package env
+
+import (
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+)
+
+/* ========================================================================= //
+	WatchSignal is the daemon's reload on SIGHUP:
+
+		stop := env.WatchSignal(syscall.SIGHUP, &myEnvVars, func(err error) {
+			if err != nil {
+				log.Printf("reload: %v", err)
+			}
+		})
+		defer stop()
+
+	Each time the signal arrives the package's own env vars are Refreshed and
+	the structure is re-read.  The read is done into a copy that is only
+	copied back if it worked, so a bad value leaves the structure as it was;
+	anything else using the structure has to be kept from doing so while it
+	is copied (onReload runs after, on the same goroutine, for telling them).
+// ------------------------------------------------------------------------- */
+
+// re-read the env vars into the structure i points to each time sig arrives, calling any onReload
+// with the read's error;  the returned stop unregisters the handler
+func WatchSignal(sig os.Signal, i interface{}, onReload func(error)) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sigs, sig)
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				err := reread(i)
+				if onReload != nil {
+					onReload(err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+		})
+	}
+}
+
+// reread -- Refresh, then read into a copy of the structure, setting it only if all went well
+func reread(i interface{}) error {
+	Refresh()
+	v, err := structPtr("WatchSignal", i)
+	if err != nil {
+		return err
+	}
+	fresh := reflect.New(v.Type())
+	fresh.Elem().Set(v) // sharing i's maps & embedded pointers, which the read replaces rather than changes
+	o := newOptions(nil)
+	o.copies = true
+	if err := readEnvVars(o, fresh.Interface()); err != nil {
+		return err
+	}
+	v.Set(fresh.Elem())
+	return nil
+}
@@ -0,0 +1,64 @@
+//go:build unix
+
+package env
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+type tWatched struct {
+	*TWatchDB
+	Port   int               `env:"WATCH_PORT"`
+	Labels map[string]string `prefix:"WATCH_LABELS_"`
+}
+
+type TWatchDB struct {
+	DBHost string `env:"WATCH_DB_HOST"`
+}
+
+func TestWatchSignal(t *testing.T) {
+	t.Setenv("WATCH_PORT", "80")
+	t.Setenv("WATCH_LABELS_a", "1")
+	t.Setenv("WATCH_DB_HOST", "db1")
+	var c tWatched
+	ReadEnvVars(&c)
+	db := c.TWatchDB
+
+	reloads := make(chan error, 1)
+	stop := WatchSignal(syscall.SIGUSR1, &c, func(err error) { reloads <- err })
+	defer stop()
+	reload := func() error {
+		if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case err := <-reloads:
+			return err
+		case <-time.After(5 * time.Second):
+			t.Fatal("no reload")
+		}
+		return nil
+	}
+
+	// a bad value:  nothing changes, not even through the map or embedded pointer shared with the copy
+	t.Setenv("WATCH_PORT", "bad")
+	t.Setenv("WATCH_LABELS_b", "2")
+	t.Setenv("WATCH_DB_HOST", "db2")
+	if err := reload(); err == nil {
+		t.Error("WATCH_PORT=bad reloaded")
+	}
+	if c.Port != 80 || len(c.Labels) != 1 || c.TWatchDB != db || db.DBHost != "db1" {
+		t.Errorf("failed reload changed the structure: %+v %+v", c, *db)
+	}
+
+	t.Setenv("WATCH_PORT", "8080")
+	if err := reload(); err != nil {
+		t.Fatal(err)
+	}
+	if c.Port != 8080 || c.Labels["b"] != "2" || c.DBHost != "db2" {
+		t.Errorf("reloaded %+v %+v", c, *c.TWatchDB)
+	}
+}
@@ -0,0 +1,67 @@
+package env
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+/* ========================================================================= //
+	Which is the shell's 'which':  the first PATH entry holding an executable
+	of the name.  On windows the name is tried with each of the PATHEXT
+	extensions (.COM .EXE .BAT .CMD if unset), on unix the file needs an
+	executable bit.  A name with a directory in it is only checked itself.
+// ------------------------------------------------------------------------- */
+
+// return the path of the executable name as found in Path(), and if found
+func Which(name string) (string, bool) {
+	candidates := whichNames(name)
+	if strings.ContainsAny(name, `/\`) {
+		for _, c := range candidates {
+			if isExecutable(c) {
+				return c, true
+			}
+		}
+		return "", false
+	}
+	for _, dir := range Path() {
+		for _, c := range candidates {
+			if p := filepath.Join(dir, c); isExecutable(p) {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+// whichNames -- the file names to look for, on windows the name with PATHEXT extensions
+func whichNames(name string) []string {
+	if !IsWindows() {
+		return []string{name}
+	}
+	pathExt := os.Getenv("PATHEXT")
+	if pathExt == "" {
+		pathExt = ".COM;.EXE;.BAT;.CMD"
+	}
+	names := []string{}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range strings.Split(pathExt, ";") { // always ";", whatever envSep is
+		if e == "" {
+			continue
+		}
+		if strings.ToLower(e) == ext {
+			return []string{name} // already has one
+		}
+		names = append(names, name+e)
+	}
+	return names
+}
+
+// isExecutable -- a regular file, with an executable bit on unix
+func isExecutable(path string) bool {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false
+	}
+	return IsWindows() || fi.Mode().Perm()&0111 != 0
+}
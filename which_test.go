@@ -0,0 +1,23 @@
+package env
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWhichNames(t *testing.T) {
+	t.Cleanup(func() { Refresh() })
+	t.Setenv("PATHEXT", ".COM;.EXE;.BAT")
+	SetHost("windows") // as when IsWindows and goos disagree:  envSep is still ':'
+	want := []string{"go.COM", "go.EXE", "go.BAT"}
+	if got := whichNames("go"); !reflect.DeepEqual(got, want) {
+		t.Errorf("whichNames(go) = %q, want %q", got, want)
+	}
+	if got := whichNames("go.exe"); !reflect.DeepEqual(got, []string{"go.exe"}) {
+		t.Errorf("whichNames(go.exe) = %q", got)
+	}
+	SetHost("linux")
+	if got := whichNames("go"); !reflect.DeepEqual(got, []string{"go"}) {
+		t.Errorf("linux whichNames(go) = %q", got)
+	}
+}
@@ -0,0 +1,266 @@
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ========================================================================= //
+	WriteEnvVars is the inverse of ReadEnvVars: each capitalized field of the
+	structure is formatted and set in the environment, using the same names
+	(derived, tagged or nested) ReadEnvVars would read it from.
+
+	[]strings & []ints are joined with envSep (or the field's `sep` tag),
+	bools are written as true/false (presence bools as 1, or not at all),
+	nil pointers (and sql.Null* types not Valid) aren't written at all, maps
+	are written as one env var per key (LABELS_env=prod).
+
+	So reading back what was written gives the same values:  WriteEnvVars(&x)
+	then ReadEnvVars(&y) has y == x, with these semantics at the edges:
+		zero values are written (PORT=0, DEBUG=false), so they read back as
+		  zero even over a `default` tag
+		empty strings & slices are written empty, which ReadEnvVars takes as
+		  unset:  y's field is left alone (nil for a slice, so []string{}
+		  reads back nil) or gets its `default`
+		a slice element holding the separator, or one lone "" element,
+		  doesn't survive the join & split
+		a nil pointer isn't written, a set one is written as its value
+		fields ReadEnvVars can't read (chan, func, a *Struct that isn't
+		  embedded, a map not keyed by string) aren't written, as it leaves
+		  them alone when unset
+		a `sep` tag's slice elements read back trimmed, with empty ones
+		  dropped (unless `env:"NAME,raw"`)
+		a time.Time (written RFC3339Nano without a `layout` tag) reads back
+		  the same instant, Equal but in a fixed zone of its offset, not its
+		  *time.Location;  a layout without the fraction drops it too
+		a net.IP reads back in its 16 byte form, a *big.Float at 64 bit
+		  precision, both equal (IP.Equal, Cmp) if not identical
+		an `expand` tag's value holding a '$' is expanded again
+
+	ToEnviron does the same without touching the environment, returning
+	KEY=VALUE strings for an exec.Cmd's Env instead.
+// ------------------------------------------------------------------------- */
+
+// set the env vars from any structure passed, panics on any error
+func WriteEnvVars(i interface{}) {
+	if err := WriteEnvVarsErr(i); err != nil {
+		panic(err.Error())
+	}
+}
+
+// set the env vars from any structure passed, returns the first error found
+func WriteEnvVarsErr(i interface{}) error {
+	return writeEnv("WriteEnvVars", i, os.Setenv)
+}
+
+// return the KEY=VALUE strings WriteEnvVars would set, for exec.Cmd.Env, panics on any error
+func ToEnviron(i interface{}) []string {
+	environ := []string{}
+	err := writeEnv("ToEnviron", i, func(name, envVal string) error {
+		environ = append(environ, name+"="+envVal)
+		return nil
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+	return environ
+}
+
+// writeEnv -- walk the structure as ReadEnvVars does, calling set with each env var's name & formatted value
+func writeEnv(who string, i interface{}, set func(name, envVal string) error) error {
+	v, err := structPtr(who, i)
+	if err != nil {
+		return err
+	}
+	o := newOptions(nil)
+	o.skipNil = true // nothing to write
+	var write walkFn
+	write = func(tags fieldTags, path string, field reflect.Value) error {
+		if field.Kind() == reflect.Map && tags.format == "" {
+			return writeEnvMap(tags, path, field, set, who)
+		}
+		if structSlice(field.Type()) && tags.format == "" {
+			for n := 0; n < field.Len(); n++ {
+				prefix := fmt.Sprintf("%s_%d_", tags.name, n)
+				if err := walkStruct(o, prefix, fmt.Sprintf("%s[%d].", path, n), field.Index(n), write); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		envVal, ok, err := fmtEnvVal(tags, field)
+		if err != nil {
+			return fmt.Errorf("%s: field %s: %v", who, path, err)
+		}
+		if ok {
+			if err := set(tags.name, envVal); err != nil {
+				return fmt.Errorf("%s: field %s: %v", who, path, err)
+			}
+		}
+		return nil
+	}
+	return walkStruct(o, o.prefix, "", v, write)
+}
+
+// writeEnvMap -- write each key of a map field as its own env var, in key order
+func writeEnvMap(tags fieldTags, path string, field reflect.Value, set func(name, envVal string) error, who string) error {
+	if field.Type().Key().Kind() != reflect.String {
+		return nil // not keyed by name, so nothing ReadEnvVars could read back
+	}
+	keys := make([]string, 0, field.Len())
+	for _, k := range field.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		k := reflect.ValueOf(key).Convert(field.Type().Key())
+		name := tags.mapPrefix() + key
+		envVal, ok, err := fmtEnvVal(fieldTags{name: name, sep: tags.sep, base: tags.base, layout: tags.layout}, field.MapIndex(k))
+		if err == nil && ok {
+			err = set(name, envVal)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: field %s: %v", who, path, err)
+		}
+	}
+	return nil
+}
+
+// format the element as setEnvVal would read it back, false if there's nothing to write (nil pointer)
+func fmtEnvVal(tags fieldTags, field reflect.Value) (string, bool, error) {
+	if tags.format == "json" {
+		b, err := json.Marshal(field.Interface())
+		return string(b), err == nil, err
+	}
+
+	if tags.presence && field.Kind() == reflect.Bool {
+		return "1", field.Bool(), nil // false is not being set
+	}
+
+	if _, ok := parserFor(field.Type()); ok {
+		return fmtParsed(tags, field)
+	}
+	if nullTypes[field.Type()] {
+		if !field.FieldByName("Valid").Bool() {
+			return "", false, nil
+		}
+		return fmtEnvVal(tags, field.Field(0))
+	}
+
+	switch field.Type() {
+	case durationType:
+		return time.Duration(field.Int()).String(), true, nil
+	case timeType:
+		layout := tags.layout
+		if layout == "" {
+			layout = time.RFC3339Nano // read as RFC3339, which takes the fraction
+		}
+		return field.Interface().(time.Time).Format(layout), true, nil
+	case ipType:
+		if field.Len() == 0 {
+			return "", true, nil
+		}
+		return field.Interface().(net.IP).String(), true, nil
+	case urlType:
+		u := field.Interface().(url.URL)
+		return u.String(), true, nil
+	case ipNetsType:
+		parts := make([]string, field.Len())
+		for n := range parts {
+			ipNet := field.Index(n).Interface().(net.IPNet)
+			parts[n] = ipNet.String()
+		}
+		return strings.Join(parts, tags.joiner()), true, nil
+	case bigIntType:
+		if field.IsNil() {
+			return "", false, nil
+		}
+		return field.Interface().(*big.Int).Text(writeBase(tags)), true, nil
+	case bigFloatType:
+		if field.IsNil() {
+			return "", false, nil
+		}
+		return field.Interface().(*big.Float).Text('g', -1), true, nil
+	case fileModeType:
+		return fmt.Sprintf("%04o", field.Uint()), true, nil
+	case ipNetType:
+		ipNet := field.Interface().(net.IPNet)
+		if ipNet.IP == nil {
+			return "", true, nil
+		}
+		return ipNet.String(), true, nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		return field.String(), true, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(field.Int(), writeBase(tags)), true, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(field.Uint(), writeBase(tags)), true, nil
+	case reflect.Bool:
+		return strconv.FormatBool(field.Bool()), true, nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(field.Float(), 'g', -1, field.Type().Bits()), true, nil
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(field.Complex(), 'g', -1, field.Type().Bits()), true, nil
+	case reflect.Slice:
+		switch field.Type().Elem().Kind() {
+		case reflect.Uint8:
+			envVal, err := tags.encode(field.Bytes())
+			return envVal, err == nil, err
+		case reflect.String, reflect.Int:
+			parts := make([]string, field.Len())
+			for n := range parts {
+				if elem := field.Index(n); elem.Kind() == reflect.String {
+					parts[n] = elem.String()
+				} else {
+					parts[n] = strconv.FormatInt(elem.Int(), writeBase(tags)) // not fmt, a named type's String() wouldn't read back
+				}
+			}
+			return strings.Join(parts, tags.joiner()), true, nil
+		default:
+			return fmtElems(tags, field)
+		}
+	case reflect.Array:
+		return fmtElems(tags, field)
+	case reflect.Ptr, reflect.Interface:
+		if field.IsNil() {
+			return "", false, nil
+		}
+		return fmtEnvVal(tags, field.Elem())
+	default:
+		// chan, func, a *Struct not nested...:  ReadEnvVars leaves these alone unset, so they aren't written
+		return "", false, nil
+	}
+}
+
+// writeBase -- the base to write an integer in:  its `base` tag's, or decimal, which any base 0 read takes
+func writeBase(tags fieldTags) int {
+	if base, err := tags.intBase(10); err == nil && base != 0 {
+		return base
+	}
+	return 10
+}
+
+// fmtElems -- format each element of the slice or array as a value of its own, joined
+func fmtElems(tags fieldTags, field reflect.Value) (string, bool, error) {
+	parts := make([]string, field.Len())
+	for n := range parts {
+		part, _, err := fmtEnvVal(fieldTags{name: tags.name, base: tags.base, layout: tags.layout}, field.Index(n))
+		if err != nil {
+			return "", false, err
+		}
+		parts[n] = part
+	}
+	return strings.Join(parts, tags.joiner()), true, nil
+}
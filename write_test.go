@@ -0,0 +1,196 @@
+package env
+
+import (
+	"database/sql"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// environMapOf -- ToEnviron's KEY=VALUE strings as a map, for reading back with FromMap
+func environMapOf(environ []string) map[string]string {
+	vars := map[string]string{}
+	for _, kv := range environ {
+		if name, envVal, ok := strings.Cut(kv, "="); ok {
+			vars[name] = envVal
+		}
+	}
+	return vars
+}
+
+type rtServer struct {
+	Host string
+	Port int
+}
+
+// TestRoundTrip -- what is written reads back the same, or as write.go's header says for the edge cases
+func TestRoundTrip(t *testing.T) {
+	port, nyc := 8080, time.FixedZone("EST", -5*60*60)
+	when := time.Date(2024, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	u, _ := url.Parse("https://user@example.com:8443/path?q=1")
+	_, cidr, _ := net.ParseCIDR("10.0.0.0/8")
+
+	tests := []struct {
+		name string
+		x    interface{}                 // a pointer to the struct written
+		same func(x, y interface{}) bool // if not DeepEqual, how y compares
+	}{
+		{name: "scalars", x: &struct {
+			S   string
+			I   int
+			I8  int8
+			U   uint16
+			F   float64
+			B   bool
+			C   complex128
+			D   time.Duration
+			Hex int `base:"16"`
+		}{"hello world", -42, -8, 65535, 3.141592653589793, true, 1 + 2i, 90 * time.Second, 255}},
+		{name: "time", x: &struct{ T time.Time }{when}},
+		{name: "time layout", x: &struct {
+			T time.Time `layout:"2006-01-02"`
+		}{time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)}},
+		{name: "lists", x: &struct {
+			S   []string
+			I   []int
+			F   []float64
+			D   []time.Duration
+			A   [3]int
+			Sep []string `sep:","`
+			Hex []byte   `encoding:"hex"`
+			Raw []byte
+		}{[]string{"a", "b"}, []int{1, -2}, []float64{0.5, 2}, []time.Duration{time.Second, time.Minute}, [3]int{1, 2, 3}, []string{"x", "y"}, []byte{0, 0xff}, []byte("raw")}},
+		{name: "maps", x: &struct {
+			Labels map[string]string
+			Limits map[string]int
+		}{map[string]string{"env": "prod", "team": "core"}, map[string]int{"cpu": 2}}},
+		{name: "pointers", x: &struct {
+			Port *int
+			Name *string
+		}{&port, nil}},
+		{name: "nested", x: &struct {
+			DB      rtServer
+			Servers []rtServer
+		}{rtServer{"db", 5432}, []rtServer{{"a", 1}, {"b", 2}}}},
+		{name: "types", x: &struct {
+			Net   net.IPNet
+			URL   url.URL
+			Mode  os.FileMode
+			Count sql.NullInt64
+			None  sql.NullString
+			Big   *big.Int
+			NoCol bool `env:"RT_NO_COLOR,presence"`
+		}{*cidr, *u, 0640, sql.NullInt64{Int64: 7, Valid: true}, sql.NullString{}, big.NewInt(1 << 62), true}},
+
+		// the edge cases
+		{name: "time zone", x: &struct{ T time.Time }{when.In(nyc)}, same: func(x, y interface{}) bool {
+			return x.(*struct{ T time.Time }).T.Equal(y.(*struct{ T time.Time }).T)
+		}},
+		{name: "sep trims", x: &struct {
+			Tags []string `sep:","`
+		}{[]string{" a", "", "b "}}, same: func(_, y interface{}) bool {
+			return reflect.DeepEqual(y.(*struct {
+				Tags []string `sep:","`
+			}).Tags, []string{"a", "b"})
+		}},
+		{name: "ip & big.Float", x: &struct {
+			IP net.IP
+			F  *big.Float
+		}{net.IP{10, 0, 0, 1}, big.NewFloat(1.5)}, same: func(x, y interface{}) bool {
+			a, b := x.(*struct {
+				IP net.IP
+				F  *big.Float
+			}), y.(*struct {
+				IP net.IP
+				F  *big.Float
+			})
+			return a.IP.Equal(b.IP) && a.F.Cmp(b.F) == 0
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			vars := environMapOf(ToEnviron(tc.x))
+			y := reflect.New(reflect.TypeOf(tc.x).Elem()).Interface()
+			if err := ReadEnvVarsFromErr(vars, y); err != nil {
+				t.Fatalf("reading back %v: %v", vars, err)
+			}
+			same := reflect.DeepEqual(tc.x, y)
+			if tc.same != nil {
+				same = tc.same(tc.x, y)
+			}
+			if !same {
+				t.Errorf("wrote %+v as %v, read back %+v", tc.x, vars, y)
+			}
+		})
+	}
+}
+
+func TestWriteEnvVars(t *testing.T) {
+	x := struct {
+		Name string `env:"RT_NAME"`
+		Port int    `env:"RT_PORT" default:"80"`
+	}{"svc", 0}
+	t.Setenv("RT_NAME", "")
+	t.Setenv("RT_PORT", "") // so both are put back after
+	WriteEnvVars(&x)
+	var y struct {
+		Name string `env:"RT_NAME"`
+		Port int    `env:"RT_PORT" default:"80"`
+	}
+	ReadEnvVars(&y)
+	if y != x {
+		t.Errorf("wrote %+v, read back %+v", x, y)
+	}
+}
+
+func TestWriteSkipsUnreadable(t *testing.T) {
+	type inner struct{ Host string }
+	x := struct {
+		Name   string
+		Fn     func()
+		Ch     chan int
+		DB     *inner
+		ByID   map[int]string
+		Any    interface{}
+		Reader interface{ Read([]byte) (int, error) }
+	}{Name: "svc", Fn: func() {}, Ch: make(chan int), DB: &inner{"db"}, ByID: map[int]string{1: "a"}, Any: func() {}, Reader: os.Stdin}
+	var y struct {
+		Name   string
+		Fn     func()
+		Ch     chan int
+		DB     *inner
+		ByID   map[int]string
+		Any    interface{}
+		Reader interface{ Read([]byte) (int, error) }
+	}
+	environ := ToEnviron(&x)
+	if len(environ) != 1 || environ[0] != "NAME=svc" {
+		t.Errorf("ToEnviron = %q, want only NAME=svc", environ)
+	}
+	if err := ReadEnvVarsFromErr(environMapOf(environ), &y); err != nil || y.Name != "svc" {
+		t.Errorf("reading back %q: %+v, %v", environ, y, err)
+	}
+}
+
+func TestWriteNotStructPointer(t *testing.T) {
+	port := 0
+	for _, i := range []interface{}{struct{ A string }{"x"}, &port, (*struct{ A string })(nil), nil} {
+		if err := WriteEnvVarsErr(i); err == nil {
+			t.Errorf("WriteEnvVarsErr(%T) wrote, want an error", i)
+		}
+		if snap := Snapshot(i); snap != nil {
+			t.Errorf("Snapshot(%T) = %v, want nil", i, snap)
+		}
+		if dump := Dump(i); !strings.Contains(dump, "not a pointer to a struct") {
+			t.Errorf("Dump(%T) = %q", i, dump)
+		}
+		if err := reread(i); err == nil {
+			t.Errorf("reread(%T) read, want an error", i)
+		}
+	}
+}